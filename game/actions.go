@@ -0,0 +1,510 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Action is something the player can do, triggered by whatever buttons are
+// bound to it
+type Action string
+
+const (
+	// Global
+	ActionBack             Action = "Back"
+	ActionToggleEdit       Action = "ToggleEdit"
+	ActionToggleGrid       Action = "ToggleGrid"
+	ActionToggleDebug      Action = "ToggleDebug"
+	ActionToggleStats      Action = "ToggleStats"
+	ActionToggleRecording  Action = "ToggleRecording"
+	ActionToggleFullscreen Action = "ToggleFullscreen"
+
+	// Driving
+	ActionAccelerate    Action = "Accelerate"
+	ActionReverse       Action = "Reverse"
+	ActionBrake         Action = "Brake"
+	ActionResetCar      Action = "ResetCar"
+	ActionPause         Action = "Pause"
+	ActionRestart       Action = "Restart"
+	ActionLoadEditLevel Action = "LoadEditLevel"
+	ActionEvolve        Action = "Evolve"
+	ActionExportVehicle Action = "ExportVehicle"
+	ActionPush          Action = "Push"
+
+	// Menus
+	ActionMenuUp        Action = "MenuUp"
+	ActionMenuDown      Action = "MenuDown"
+	ActionConfirm       Action = "Confirm"
+	ActionLeaderboard   Action = "Leaderboard"
+	ActionDeleteProfile Action = "DeleteProfile"
+
+	// Editor
+	ActionEditSelect      Action = "EditSelect"
+	ActionEditNewBody     Action = "EditNewBody"
+	ActionEditNewCargo    Action = "EditNewCargo"
+	ActionEditSave        Action = "EditSave"
+	ActionEditSaveAs      Action = "EditSaveAs"
+	ActionEditOpen        Action = "EditOpen"
+	ActionEditPanLeft     Action = "EditPanLeft"
+	ActionEditPanRight    Action = "EditPanRight"
+	ActionEditPanDrag     Action = "EditPanDrag"
+	ActionEditFrameAll    Action = "EditFrameAll"
+	ActionEditRotateLeft  Action = "EditRotateLeft"
+	ActionEditRotateRight Action = "EditRotateRight"
+	ActionEditUndo        Action = "EditUndo"
+	ActionEditRedo        Action = "EditRedo"
+	ActionEditPaste       Action = "EditPaste"
+	ActionEditSnapGrid    Action = "EditSnapGrid"
+	ActionEditSnapAngle   Action = "EditSnapAngle"
+	ActionEditSnapEdges   Action = "EditSnapEdges"
+	ActionEditGridFiner   Action = "EditGridFiner"
+	ActionEditGridCoarser Action = "EditGridCoarser"
+	ActionEditNewJoint    Action = "EditNewJoint"
+
+	ActionEditLessDensity  Action = "EditLessDensity"
+	ActionEditMoreDensity  Action = "EditMoreDensity"
+	ActionEditLessFriction Action = "EditLessFriction"
+	ActionEditMoreFriction Action = "EditMoreFriction"
+	ActionEditNextField    Action = "EditNextField"
+	ActionEditPrevField    Action = "EditPrevField"
+	ActionEditDecrease     Action = "EditDecrease"
+	ActionEditIncrease     Action = "EditIncrease"
+
+	// Editor, while placing a new body
+	ActionEditSwapShape    Action = "EditSwapShape"
+	ActionEditGrowWidth    Action = "EditGrowWidth"
+	ActionEditShrinkWidth  Action = "EditShrinkWidth"
+	ActionEditGrowHeight   Action = "EditGrowHeight"
+	ActionEditShrinkHeight Action = "EditShrinkHeight"
+
+	// Editor, with a body selected
+	ActionEditMoveLeft       Action = "EditMoveLeft"
+	ActionEditMoveRight      Action = "EditMoveRight"
+	ActionEditMoveUp         Action = "EditMoveUp"
+	ActionEditMoveDown       Action = "EditMoveDown"
+	ActionEditDelete         Action = "EditDelete"
+	ActionEditFrameSelection Action = "EditFrameSelection"
+	ActionEditCopy           Action = "EditCopy"
+	ActionEditDuplicate      Action = "EditDuplicate"
+)
+
+// ActionContext groups actions that can be used at the same time. A button may
+// be bound in contexts that never overlap, like the arrows driving the car in
+// play and moving bodies in the editor.
+type ActionContext int
+
+const (
+	ContextGlobal ActionContext = iota
+	ContextPlay
+	ContextMenu
+	ContextEdit
+	ContextPlacement
+	ContextSelection
+)
+
+func (c ActionContext) overlaps(other ActionContext) bool {
+	if c == other || c == ContextGlobal || other == ContextGlobal {
+		return true
+	}
+	// The editor's own actions stay active while placing or with a selection
+	sub := func(a ActionContext) bool { return a == ContextPlacement || a == ContextSelection }
+	return c == ContextEdit && sub(other) || other == ContextEdit && sub(c)
+}
+
+type actionInfo struct {
+	action      Action
+	context     ActionContext
+	description string
+	defaults    []string
+}
+
+// actionList holds every action in the order the controls screen shows them
+var actionList = []actionInfo{
+	{ActionAccelerate, ContextPlay, "Accelerate", []string{"Right"}},
+	{ActionReverse, ContextPlay, "Reverse", []string{"Left"}},
+	{ActionBrake, ContextPlay, "Brake", []string{"Space"}},
+	{ActionResetCar, ContextPlay, "Reset car", []string{"1"}},
+	{ActionPause, ContextPlay, "Pause", []string{"P"}},
+	{ActionRestart, ContextPlay, "Restart level", []string{"Enter"}},
+	{ActionLoadEditLevel, ContextPlay, "Play edited level", []string{"L"}},
+	{ActionEvolve, ContextPlay, "Evolve a vehicle", []string{"V"}},
+	{ActionExportVehicle, ContextPlay, "Export evolved vehicle", []string{"S"}},
+	{ActionPush, ContextPlay, "Push bodies", []string{"MouseButtonLeft"}},
+
+	{ActionBack, ContextGlobal, "Back", []string{"Escape"}},
+	{ActionToggleEdit, ContextGlobal, "Toggle editor", []string{"E"}},
+	{ActionToggleGrid, ContextGlobal, "Toggle grid", []string{"M"}},
+	{ActionToggleDebug, ContextGlobal, "Physics debug", []string{"F3"}},
+	{ActionToggleStats, ContextGlobal, "Performance stats", []string{"F2"}},
+	{ActionToggleRecording, ContextGlobal, "Record profile", []string{"F4"}},
+	{ActionToggleFullscreen, ContextGlobal, "Fullscreen", []string{"F11"}},
+
+	{ActionMenuUp, ContextMenu, "Menu up", []string{"Up"}},
+	{ActionMenuDown, ContextMenu, "Menu down", []string{"Down"}},
+	{ActionConfirm, ContextMenu, "Confirm", []string{"Enter"}},
+	{ActionLeaderboard, ContextMenu, "Leaderboard", []string{"L"}},
+	{ActionDeleteProfile, ContextMenu, "Delete profile", []string{"Delete"}},
+
+	{ActionEditSelect, ContextEdit, "Select and place", []string{"MouseButtonLeft"}},
+	{ActionEditNewBody, ContextEdit, "New body", []string{"N"}},
+	{ActionEditNewCargo, ContextEdit, "New cargo", []string{"C"}},
+	{ActionEditSave, ContextEdit, "Save level", []string{"S"}},
+	{ActionEditSaveAs, ContextEdit, "Save level as", []string{"Shift+S"}},
+	{ActionEditOpen, ContextEdit, "Open level", []string{"O"}},
+	{ActionEditPanLeft, ContextEdit, "Pan left", []string{"A"}},
+	{ActionEditPanRight, ContextEdit, "Pan right", []string{"D"}},
+	{ActionEditPanDrag, ContextEdit, "Drag to pan", []string{"MouseButtonMiddle"}},
+	{ActionEditFrameAll, ContextEdit, "Frame level", []string{"Home"}},
+	{ActionEditRotateLeft, ContextEdit, "Rotate left", []string{"Comma"}},
+	{ActionEditRotateRight, ContextEdit, "Rotate right", []string{"Period"}},
+	{ActionEditUndo, ContextEdit, "Undo", []string{"Ctrl+Z"}},
+	{ActionEditRedo, ContextEdit, "Redo", []string{"Ctrl+Y"}},
+	{ActionEditPaste, ContextEdit, "Paste", []string{"Ctrl+V"}},
+	{ActionEditSnapGrid, ContextEdit, "Snap to grid", []string{"X"}},
+	{ActionEditSnapAngle, ContextEdit, "Snap angles", []string{"Z"}},
+	{ActionEditSnapEdges, ContextEdit, "Snap to edges", []string{"B"}},
+	{ActionEditGridFiner, ContextEdit, "Finer grid", []string{"LeftBracket"}},
+	{ActionEditGridCoarser, ContextEdit, "Coarser grid", []string{"RightBracket"}},
+	{ActionEditNewJoint, ContextEdit, "New joint", []string{"J"}},
+	{ActionEditLessDensity, ContextEdit, "Less density", []string{"R"}},
+	{ActionEditMoreDensity, ContextEdit, "More density", []string{"T"}},
+	{ActionEditLessFriction, ContextEdit, "Less friction", []string{"G"}},
+	{ActionEditMoreFriction, ContextEdit, "More friction", []string{"H"}},
+	{ActionEditNextField, ContextEdit, "Next property", []string{"Tab"}},
+	{ActionEditPrevField, ContextEdit, "Previous property", []string{"Shift+Tab"}},
+	{ActionEditDecrease, ContextEdit, "Decrease property", []string{"Minus"}},
+	{ActionEditIncrease, ContextEdit, "Increase property", []string{"Equal"}},
+
+	{ActionEditSwapShape, ContextPlacement, "Swap shape", []string{"V"}},
+	{ActionEditGrowWidth, ContextPlacement, "Wider", []string{"Right"}},
+	{ActionEditShrinkWidth, ContextPlacement, "Narrower", []string{"Left"}},
+	{ActionEditGrowHeight, ContextPlacement, "Taller", []string{"Up"}},
+	{ActionEditShrinkHeight, ContextPlacement, "Shorter", []string{"Down"}},
+
+	{ActionEditMoveLeft, ContextSelection, "Move left", []string{"Left"}},
+	{ActionEditMoveRight, ContextSelection, "Move right", []string{"Right"}},
+	{ActionEditMoveUp, ContextSelection, "Move up", []string{"Up"}},
+	{ActionEditMoveDown, ContextSelection, "Move down", []string{"Down"}},
+	{ActionEditDelete, ContextSelection, "Delete selection", []string{"Delete"}},
+	{ActionEditFrameSelection, ContextSelection, "Frame selection", []string{"F"}},
+	{ActionEditCopy, ContextSelection, "Copy", []string{"Ctrl+C"}},
+	{ActionEditDuplicate, ContextSelection, "Duplicate", []string{"Ctrl+D"}},
+}
+
+func findAction(action Action) (actionInfo, bool) {
+	for _, info := range actionList {
+		if info.action == action {
+			return info, true
+		}
+	}
+	return actionInfo{}, false
+}
+
+// Modifier is a set of modifier keys that must be held for a binding
+type Modifier uint8
+
+const (
+	ModCtrl Modifier = 1 << iota
+	ModShift
+	ModAlt
+)
+
+var modifierNames = []struct {
+	mod  Modifier
+	name string
+}{{ModCtrl, "Ctrl"}, {ModShift, "Shift"}, {ModAlt, "Alt"}}
+
+// Binding is a button, optionally combined with modifiers like in "Ctrl+Z"
+type Binding struct {
+	Button pixelgl.Button
+	Mods   Modifier
+}
+
+func (b Binding) String() string {
+	var parts []string
+	for _, m := range modifierNames {
+		if b.Mods&m.mod != 0 {
+			parts = append(parts, m.name)
+		}
+	}
+	return strings.Join(append(parts, b.Button.String()), "+")
+}
+
+var buttonsByName map[string]pixelgl.Button
+
+// allButtons lists every mouse button and key pixelgl knows
+func allButtons() []pixelgl.Button {
+	var buttons []pixelgl.Button
+	for b := pixelgl.MouseButton1; b <= pixelgl.MouseButtonLast; b++ {
+		buttons = append(buttons, b)
+	}
+	for b := pixelgl.KeySpace; b <= pixelgl.KeyLast; b++ {
+		if b.String() != "Invalid" {
+			buttons = append(buttons, b)
+		}
+	}
+	return buttons
+}
+
+// ParseBinding reads a binding written like "Ctrl+Shift+Z", using the button
+// names of pixelgl
+func ParseBinding(s string) (Binding, error) {
+	if buttonsByName == nil {
+		buttonsByName = map[string]pixelgl.Button{}
+		for _, b := range allButtons() {
+			buttonsByName[strings.ToLower(b.String())] = b
+		}
+	}
+
+	binding := Binding{}
+	parts := strings.Split(s, "+")
+	for _, part := range parts[:len(parts)-1] {
+		found := false
+		for _, m := range modifierNames {
+			if strings.EqualFold(strings.TrimSpace(part), m.name) {
+				binding.Mods |= m.mod
+				found = true
+			}
+		}
+		if !found {
+			return binding, fmt.Errorf("unknown modifier %q in %q", part, s)
+		}
+	}
+	button, ok := buttonsByName[strings.ToLower(strings.TrimSpace(parts[len(parts)-1]))]
+	if !ok {
+		return binding, fmt.Errorf("unknown button in %q", s)
+	}
+	binding.Button = button
+	return binding, nil
+}
+
+// ActionMap binds actions to buttons
+type ActionMap struct {
+	bindings map[Action][]Binding
+}
+
+// DefaultActionMap has the built in bindings of every action
+func DefaultActionMap() *ActionMap {
+	m := &ActionMap{bindings: map[Action][]Binding{}}
+	for _, info := range actionList {
+		for _, name := range info.defaults {
+			binding, err := ParseBinding(name)
+			if err != nil {
+				panic(err)
+			}
+			m.bindings[info.action] = append(m.bindings[info.action], binding)
+		}
+	}
+	return m
+}
+
+func (m *ActionMap) Clone() *ActionMap {
+	clone := &ActionMap{bindings: map[Action][]Binding{}}
+	for action, bindings := range m.bindings {
+		clone.bindings[action] = append([]Binding(nil), bindings...)
+	}
+	return clone
+}
+
+// Load replaces the bindings of the actions listed, as written in config files.
+// Unknown actions and buttons are reported and skipped.
+func (m *ActionMap) Load(bindings map[Action][]string) {
+	for action, names := range bindings {
+		if _, ok := findAction(action); !ok {
+			fmt.Println("Unknown action in bindings:", action)
+			continue
+		}
+		var parsed []Binding
+		for _, name := range names {
+			binding, err := ParseBinding(name)
+			if err != nil {
+				fmt.Println("Bad binding:", err)
+				continue
+			}
+			parsed = append(parsed, binding)
+		}
+		m.bindings[action] = parsed
+	}
+}
+
+// Diff lists the bindings that differ from base, in the form Load reads
+func (m *ActionMap) Diff(base *ActionMap) map[Action][]string {
+	diff := map[Action][]string{}
+	for _, info := range actionList {
+		mine, theirs := m.bindings[info.action], base.bindings[info.action]
+		if bindingsEqual(mine, theirs) {
+			continue
+		}
+		names := []string{}
+		for _, b := range mine {
+			names = append(names, b.String())
+		}
+		diff[info.action] = names
+	}
+	return diff
+}
+
+func bindingsEqual(a, b []Binding) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *ActionMap) Bindings(action Action) []Binding {
+	return m.bindings[action]
+}
+
+func (m *ActionMap) Bind(action Action, bindings []Binding) {
+	m.bindings[action] = bindings
+}
+
+// Conflicts lists the other actions that binding would trigger as well
+func (m *ActionMap) Conflicts(action Action, binding Binding) []Action {
+	info, _ := findAction(action)
+	var conflicts []Action
+	for _, other := range actionList {
+		if other.action == action || !info.context.overlaps(other.context) {
+			continue
+		}
+		for _, b := range m.bindings[other.action] {
+			if b == binding {
+				conflicts = append(conflicts, other.action)
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+// Rebind makes binding the only binding of action. Actions that used the same
+// binding take over the old binding of action, so nothing ends up unbound.
+// It returns the actions that were changed that way.
+func (m *ActionMap) Rebind(action Action, binding Binding) []Action {
+	old := m.bindings[action]
+	conflicts := m.Conflicts(action, binding)
+	for _, other := range conflicts {
+		var kept []Binding
+		for _, b := range m.bindings[other] {
+			if b != binding {
+				kept = append(kept, b)
+			}
+		}
+		if len(old) > 0 {
+			kept = append(kept, old[0])
+		}
+		m.bindings[other] = kept
+	}
+	m.bindings[action] = []Binding{binding}
+	return conflicts
+}
+
+// Key is a short description of what to press for action, for help texts
+func (m *ActionMap) Key(action Action) string {
+	bindings := m.bindings[action]
+	if len(bindings) == 0 {
+		return "(unbound)"
+	}
+	names := make([]string, len(bindings))
+	for i, b := range bindings {
+		names[i] = b.String()
+	}
+	return strings.Join(names, " or ")
+}
+
+// shiftHeld reports whether either shift key is down
+func shiftHeld(in InputSource) bool {
+	return in.Pressed(pixelgl.KeyLeftShift) || in.Pressed(pixelgl.KeyRightShift)
+}
+
+// modsHeld reports whether exactly the modifiers a binding needs are held.
+// Shift is let through when not asked for so shift clicks still count.
+func modsHeld(in InputSource, mods Modifier) bool {
+	ctrl := in.Pressed(pixelgl.KeyLeftControl) || in.Pressed(pixelgl.KeyRightControl)
+	shift := shiftHeld(in)
+	alt := in.Pressed(pixelgl.KeyLeftAlt) || in.Pressed(pixelgl.KeyRightAlt)
+	if ctrl != (mods&ModCtrl != 0) || alt != (mods&ModAlt != 0) {
+		return false
+	}
+	return shift || mods&ModShift == 0
+}
+
+func (m *ActionMap) check(in InputSource, action Action, pressed func(pixelgl.Button) bool) bool {
+	for _, b := range m.bindings[action] {
+		if pressed(b.Button) && modsHeld(in, b.Mods) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pressed reports whether action is held down
+func (m *ActionMap) Pressed(in InputSource, action Action) bool {
+	return m.check(in, action, in.Pressed)
+}
+
+func (m *ActionMap) JustPressed(in InputSource, action Action) bool {
+	return m.check(in, action, in.JustPressed)
+}
+
+func (m *ActionMap) JustReleased(in InputSource, action Action) bool {
+	for _, b := range m.bindings[action] {
+		if in.JustReleased(b.Button) {
+			return true
+		}
+	}
+	return false
+}
+
+// Repeated is JustPressed plus the key repeats of holding it down
+func (m *ActionMap) Repeated(in InputSource, action Action) bool {
+	return m.check(in, action, func(b pixelgl.Button) bool {
+		return in.JustPressed(b) || in.Repeated(b)
+	})
+}
+
+// loadActions builds the bindings from the defaults, the config and the
+// profile, in that order, and warns about bindings that clash
+func (g *Game) loadActions() {
+	base := DefaultActionMap()
+	base.Load(g.config.Bindings)
+	g.baseActions = base
+
+	g.actions = base.Clone()
+	g.actions.Load(g.profile.Settings.Bindings)
+	for _, info := range actionList {
+		for _, b := range g.actions.Bindings(info.action) {
+			for _, other := range g.actions.Conflicts(info.action, b) {
+				fmt.Printf("Binding %s is used by both %s and %s\n", b, info.action, other)
+			}
+		}
+	}
+}
+
+func (g *Game) pressed(action Action) bool {
+	return g.actions.Pressed(g.input, action)
+}
+
+func (g *Game) justPressed(action Action) bool {
+	return g.actions.JustPressed(g.input, action)
+}
+
+func (g *Game) justReleased(action Action) bool {
+	return g.actions.JustReleased(g.input, action)
+}
+
+func (g *Game) repeated(action Action) bool {
+	return g.actions.Repeated(g.input, action)
+}
+
+// key is the binding of action for help texts
+func (g *Game) key(action Action) string {
+	return g.actions.Key(action)
+}
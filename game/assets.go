@@ -0,0 +1,41 @@
+package game
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/faiface/pixel"
+)
+
+const AssetRoot = "./resources"
+
+// AssetManager loads textures by name from the resources folder and caches them
+type AssetManager struct {
+	root     string
+	pictures map[string]pixel.Picture
+	missing  map[string]bool
+}
+
+func NewAssetManager(root string) *AssetManager {
+	return &AssetManager{root: root, pictures: map[string]pixel.Picture{}, missing: map[string]bool{}}
+}
+
+// Picture returns the texture called name, loading it on first use. A texture
+// that can't be loaded is reported once and returns nil from then on.
+func (a *AssetManager) Picture(name string) pixel.Picture {
+	if picture, ok := a.pictures[name]; ok {
+		return picture
+	}
+	if a.missing[name] {
+		return nil
+	}
+
+	picture, err := loadPicture(filepath.Join(a.root, name))
+	if err != nil {
+		fmt.Println("Warning: could not load texture", name+":", err)
+		a.missing[name] = true
+		return nil
+	}
+	a.pictures[name] = picture
+	return picture
+}
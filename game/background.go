@@ -0,0 +1,83 @@
+package game
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+	"golang.org/x/image/colornames"
+)
+
+// Background is a loaded image layer drawn behind the level
+type Background struct {
+	sprite *pixel.Sprite
+	def    BackgroundJson
+}
+
+func (c *ColorJson) toColor() color.RGBA {
+	return color.RGBA{c.R, c.G, c.B, c.A}
+}
+
+func loadBackgrounds(assets *AssetManager, defs []BackgroundJson) []*Background {
+	var backgrounds []*Background
+	for i := 0; i < len(defs); i++ {
+		picture := assets.Picture(defs[i].Image)
+		if picture == nil {
+			continue
+		}
+		sprite := pixel.NewSprite(picture, picture.Bounds())
+		backgrounds = append(backgrounds, &Background{sprite: sprite, def: defs[i]})
+	}
+	return backgrounds
+}
+
+// ClearColor is what the window is cleared with behind the backgrounds
+func (g *Game) ClearColor() color.Color {
+	if g.levelData != nil && g.levelData.BackgroundColor != nil {
+		return g.levelData.BackgroundColor.toColor()
+	}
+	return colornames.Aliceblue
+}
+
+// Draw renders the layer. Parallax 0 keeps it fixed on screen and 1 moves it
+// with the world, values in between make it look further away.
+func (bg *Background) Draw(win *pixelgl.Window, cam *Camera) {
+	scale := bg.def.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	scale *= cam.PixelsPerMeter() / Scale
+
+	tint := color.RGBA{255, 255, 255, 255}
+	if bg.def.Tint != nil {
+		tint = bg.def.Tint.toColor()
+	}
+
+	// Screen position of the bottom left corner of the layer
+	offset := pixel.V(bg.def.OffsetX, bg.def.OffsetY).Sub(cam.Pos.Scaled(bg.def.Parallax))
+	origin := cam.Screen.Center().Add(offset.Scaled(cam.PixelsPerMeter()))
+
+	w := bg.sprite.Frame().W() * scale
+	h := bg.sprite.Frame().H() * scale
+	local := origin.Sub(cam.Screen.Min)
+	firstX, lastX := tileRange(local.X, w, cam.Screen.W(), bg.def.TileX)
+	firstY, lastY := tileRange(local.Y, h, cam.Screen.H(), bg.def.TileY)
+
+	for i := firstX; i <= lastX; i++ {
+		for j := firstY; j <= lastY; j++ {
+			center := origin.Add(pixel.V((float64(i)+0.5)*w, (float64(j)+0.5)*h))
+			bg.sprite.DrawColorMask(win, pixel.IM.Scaled(pixel.ZV, scale).Moved(center), tint)
+		}
+	}
+}
+
+// tileRange returns which tiles of size are needed to cover 0 to screen
+func tileRange(origin, size, screen float64, tile bool) (int, int) {
+	if !tile || size <= 0 {
+		return 0, 0
+	}
+	first := int(math.Floor(-origin / size))
+	last := int(math.Floor((screen - origin) / size))
+	return first, last
+}
@@ -0,0 +1,170 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+const (
+	cameraStiffness    = 4.0 // How quickly the camera catches up with its target, per second
+	cameraLookAhead    = 0.6 // Seconds of velocity to look ahead
+	cameraMaxLookAhead = 3.0
+	cameraFollowHeight = 1.0 // Keep the followed body a bit below the center of the view
+	cameraMinZoom      = 0.05
+	cameraMaxZoom      = 8.0
+	cameraZoomStep     = 1.1 // Zoom factor per mouse wheel notch
+	cameraFrameMargin  = 1.0
+)
+
+// Camera looks at Pos, the world position in the center of the screen.
+type Camera struct {
+	Pos      pixel.Vec
+	Zoom     float64
+	Rotation float64
+	// World area the view is kept inside. A zero rect means no bounds.
+	Bounds pixel.Rect
+	// Window area the view is drawn into
+	Screen    pixel.Rect
+	lookAhead pixel.Vec
+}
+
+func NewCamera() *Camera {
+	cam := &Camera{Zoom: 1, Screen: pixel.R(0, 0, ScreenWidth, ScreenHeight)}
+	cam.Pos = cam.ViewSize().Scaled(0.5)
+	return cam
+}
+
+// PixelsPerMeter is the size of a meter on screen. It grows with the window
+// height, so any window shows as much of the world vertically as the default
+// one and wider windows show more to the sides.
+func (cam *Camera) PixelsPerMeter() float64 {
+	return Scale * cam.Zoom * cam.Screen.H() / ScreenHeight
+}
+
+// Matrix transforms world coordinates in meters to screen pixels
+func (cam *Camera) Matrix() pixel.Matrix {
+	center := cam.Screen.Center()
+	return pixel.IM.Moved(cam.Pos.Scaled(-1)).Rotated(pixel.ZV, -cam.Rotation).Scaled(pixel.ZV, cam.PixelsPerMeter()).Moved(center)
+}
+
+// BodyMatrix places geometry given in unzoomed pixels around a body in world space
+func (cam *Camera) BodyMatrix(pos pixel.Vec, angle float64) pixel.Matrix {
+	return pixel.IM.Scaled(pixel.ZV, InvScale).Rotated(pixel.ZV, angle).Moved(pos).Chained(cam.Matrix())
+}
+
+func (cam *Camera) WorldToScreen(v pixel.Vec) pixel.Vec {
+	return cam.Matrix().Project(v)
+}
+
+func (cam *Camera) ScreenToWorld(v pixel.Vec) pixel.Vec {
+	return cam.Matrix().Unproject(v)
+}
+
+// ViewSize is the size of the visible area in meters
+func (cam *Camera) ViewSize() pixel.Vec {
+	return cam.Screen.Size().Scaled(1 / cam.PixelsPerMeter())
+}
+
+// View is the visible world area, ignoring rotation
+func (cam *Camera) View() pixel.Rect {
+	half := cam.ViewSize().Scaled(0.5)
+	return pixel.Rect{Min: cam.Pos.Sub(half), Max: cam.Pos.Add(half)}
+}
+
+// Follow eases the camera towards target, looking ahead in the direction of velocity
+func (cam *Camera) Follow(target, velocity pixel.Vec, dt float64) {
+	t := 1 - math.Exp(-cameraStiffness*dt)
+
+	ahead := velocity.Scaled(cameraLookAhead)
+	ahead.X = math.Max(-cameraMaxLookAhead, math.Min(cameraMaxLookAhead, ahead.X))
+	ahead.Y = math.Max(-cameraMaxLookAhead, math.Min(cameraMaxLookAhead, ahead.Y))
+	cam.lookAhead = pixel.Lerp(cam.lookAhead, ahead, t)
+
+	goal := target.Add(cam.lookAhead).Add(pixel.V(0, cameraFollowHeight))
+	cam.Pos = pixel.Lerp(cam.Pos, goal, t)
+	cam.clamp()
+}
+
+// SnapTo centers the camera on target without easing
+func (cam *Camera) SnapTo(target pixel.Vec) {
+	cam.lookAhead = pixel.ZV
+	cam.Pos = target.Add(pixel.V(0, cameraFollowHeight))
+	cam.clamp()
+}
+
+func (cam *Camera) clamp() {
+	if cam.Bounds.Area() == 0 {
+		return
+	}
+	half := cam.ViewSize().Scaled(0.5)
+	cam.Pos.X = clampView(cam.Pos.X, half.X, cam.Bounds.Min.X, cam.Bounds.Max.X)
+	cam.Pos.Y = clampView(cam.Pos.Y, half.Y, cam.Bounds.Min.Y, cam.Bounds.Max.Y)
+}
+
+// clampView keeps a view of half size half centered at pos inside min and max.
+// Views larger than the bounds stick to min, so the ground stays at the bottom.
+func clampView(pos, half, min, max float64) float64 {
+	if max-min <= half*2 {
+		return min + half
+	}
+	return math.Max(min+half, math.Min(max-half, pos))
+}
+
+// followCar moves the camera along with the car
+func (g *Game) followCar() {
+	pos := g.car.body.Body.GetPosition()
+	vel := g.car.body.Body.GetLinearVelocity()
+	g.camera.Follow(pixel.V(pos.X, pos.Y), pixel.V(vel.X, vel.Y), TimeStep)
+}
+
+// levelBounds is the area the camera may show for the loaded level. Levels can
+// set their own bounds, otherwise they span the ground and everything above it.
+func levelBounds(g *Game) pixel.Rect {
+	if g.levelData.CameraBounds != nil {
+		b := g.levelData.CameraBounds
+		return pixel.R(b.MinX, b.MinY, b.MaxX, b.MaxY)
+	}
+
+	groundPos := g.ground.Body.GetPosition()
+	bounds := pixel.R(groundPos.X-g.ground.HalfW, groundPos.Y-g.ground.HalfH, groundPos.X+g.ground.HalfW, groundPos.Y+g.ground.HalfH)
+
+	bodies := append([]*GameBody{g.goalBody}, g.Bodies...)
+	bodies = append(bodies, g.CargoBodies...)
+	for i := 0; i < len(bodies); i++ {
+		pos := bodies[i].Body.GetPosition()
+		extent := math.Max(math.Max(bodies[i].HalfW, bodies[i].HalfH), bodies[i].Radius)
+		bounds.Max.Y = math.Max(bounds.Max.Y, pos.Y+extent)
+	}
+	// Leave room to see what is above the highest body
+	bounds.Max.Y += 3
+	return bounds
+}
+
+// Frame fits rect into the view, leaving margin meters around it
+func (cam *Camera) Frame(rect pixel.Rect, margin float64) {
+	if rect.W() == 0 && rect.H() == 0 {
+		return
+	}
+	rect = rect.Norm()
+	w := rect.W() + margin*2
+	h := rect.H() + margin*2
+	meter := cam.PixelsPerMeter() / cam.Zoom
+	zoom := math.Min(cam.Screen.W()/(w*meter), cam.Screen.H()/(h*meter))
+	cam.Zoom = math.Max(cameraMinZoom, math.Min(cameraMaxZoom, zoom))
+	cam.Pos = rect.Center()
+}
+
+// ZoomAt zooms by factor while keeping the world point under screenPos in place
+func (cam *Camera) ZoomAt(screenPos pixel.Vec, factor float64) {
+	before := cam.ScreenToWorld(screenPos)
+	cam.Zoom = math.Max(cameraMinZoom, math.Min(cameraMaxZoom, cam.Zoom*factor))
+	after := cam.ScreenToWorld(screenPos)
+	cam.Pos = cam.Pos.Add(before.Sub(after))
+}
+
+// Pan moves the camera so the world point under from ends up under to
+func (cam *Camera) Pan(from, to pixel.Vec) {
+	delta := cam.ScreenToWorld(from).Sub(cam.ScreenToWorld(to))
+	cam.Pos = cam.Pos.Add(delta)
+}
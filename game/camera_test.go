@@ -0,0 +1,40 @@
+package game
+
+import "testing"
+
+// Building the world again from edited level data bounds the camera by the
+// level as edited
+func TestCameraBoundsFollowLevel(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(level *LevelData)
+		longer bool
+		higher bool
+	}{
+		{"longer ground", func(level *LevelData) {
+			ground := level.ground()
+			ground.Length += 40
+			level.Ground = &ground
+		}, true, false},
+		{"raised body", func(level *LevelData) {
+			level.Bodies[0].Y += 50
+		}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			playLevel(g, g.config.Levels[0])
+			before := g.camera.Bounds
+
+			tt.edit(g.levelData)
+			g.buildWorld(g.car.def)
+			after := g.camera.Bounds
+			if longer := after.Max.X > before.Max.X; longer != tt.longer {
+				t.Errorf("bounds end at %.1f, was %.1f", after.Max.X, before.Max.X)
+			}
+			if higher := after.Max.Y > before.Max.Y; higher != tt.higher {
+				t.Errorf("bounds top at %.1f, was %.1f", after.Max.Y, before.Max.Y)
+			}
+		})
+	}
+}
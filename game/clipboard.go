@@ -0,0 +1,169 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/faiface/mainthread"
+	"github.com/faiface/pixel"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+// duplicateOffset is how far from the originals duplicated bodies are placed
+var duplicateOffset = pixel.V(0.5, 0.5)
+
+var errNothingToPaste = errors.New("no bodies on the clipboard")
+
+// ClipboardJson is what copying in the editor puts on the clipboard. Its
+// fields match LevelData, so the text of a level file can be pasted too.
+type ClipboardJson struct {
+	Bodies []BodyJson `json:",omitempty"`
+	Cargo  []BodyJson `json:",omitempty"`
+}
+
+func newClipboardJson(bodies []*GameBody) *ClipboardJson {
+	clip := &ClipboardJson{}
+	for _, body := range bodies {
+		if body.IsCargo {
+			clip.Cargo = append(clip.Cargo, newBodyJson(body))
+		} else {
+			clip.Bodies = append(clip.Bodies, newBodyJson(body))
+		}
+	}
+	return clip
+}
+
+// parseClipboard reads clipboard text holding a ClipboardJson, a level, a
+// list of bodies or a single body. Bodies the editor can't create are left out.
+func parseClipboard(text string) (*ClipboardJson, error) {
+	text = strings.TrimSpace(text)
+	clip := &ClipboardJson{}
+	var err error
+	if strings.HasPrefix(text, "[") {
+		err = json.Unmarshal([]byte(text), &clip.Bodies)
+	} else {
+		err = json.Unmarshal([]byte(text), clip)
+		if err == nil && len(clip.Bodies) == 0 && len(clip.Cargo) == 0 {
+			body := BodyJson{}
+			err = json.Unmarshal([]byte(text), &body)
+			clip.Bodies = []BodyJson{body}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	clip.Bodies = validBodies(clip.Bodies)
+	clip.Cargo = validBodies(clip.Cargo)
+	if len(clip.Bodies) == 0 && len(clip.Cargo) == 0 {
+		return nil, errNothingToPaste
+	}
+	return clip, nil
+}
+
+func validBodies(bodies []BodyJson) []BodyJson {
+	var valid []BodyJson
+	for _, b := range bodies {
+		box := b.BodyShape == Rectangle && b.Hx > 0 && b.Hy > 0
+		ball := b.BodyShape == Circle && b.Radius > 0
+		if box || ball {
+			valid = append(valid, b)
+		}
+	}
+	return valid
+}
+
+// center is the middle of the positions of all bodies
+func (clip *ClipboardJson) center() pixel.Vec {
+	all := append(clip.Bodies[:len(clip.Bodies):len(clip.Bodies)], clip.Cargo...)
+	bounds := pixel.Rect{}
+	for i, b := range all {
+		pos := pixel.V(b.X, b.Y)
+		if i == 0 {
+			bounds = pixel.Rect{Min: pos, Max: pos}
+		}
+		bounds = bounds.Union(pixel.Rect{Min: pos, Max: pos})
+	}
+	return bounds.Center()
+}
+
+// pasteCommand creates the clipboard bodies moved by offset, at the end of
+// the body lists
+func (g *Game) pasteCommand(verb string, clip *ClipboardJson, offset pixel.Vec) EditCommand {
+	cmd := &groupCommand{verb: verb}
+	for _, cargo := range []bool{false, true} {
+		bodies := clip.Bodies
+		if cargo {
+			bodies = clip.Cargo
+		}
+		index := len(*g.bodyList(cargo))
+		for _, b := range bodies {
+			b.X += offset.X
+			b.Y += offset.Y
+			cmd.commands = append(cmd.commands, &createBodyCommand{ref: bodyRef{Cargo: cargo, Index: index}, body: b})
+			index++
+		}
+	}
+	return cmd
+}
+
+// copyBodies puts bodies on the internal clipboard and as text on the
+// system clipboard, so they can be pasted in another editor session
+func (g *Game) copyBodies(bodies []*GameBody) {
+	g.clipboard = newClipboardJson(bodies)
+	if g.Window == nil {
+		return
+	}
+	data, err := json.MarshalIndent(g.clipboard, "", " ")
+	if err != nil {
+		fmt.Println("Copying failed:", err)
+		return
+	}
+	// glfw may only be called from the main thread
+	mainthread.Call(func() {
+		glfw.SetClipboardString(string(data))
+	})
+}
+
+// pasteClipboard reads the system clipboard, falling back to the internal one
+// when it holds nothing the editor understands
+func (g *Game) pasteClipboard() *ClipboardJson {
+	if g.Window != nil {
+		var text string
+		mainthread.Call(func() {
+			text = glfw.GetClipboardString()
+		})
+		if clip, err := parseClipboard(text); err == nil {
+			return clip
+		}
+	}
+	return g.clipboard
+}
+
+// handleEditClipboard copies, pastes at the cursor and duplicates. It returns
+// true when the selection changed.
+func handleEditClipboard(g *Game, selection []*GameBody) bool {
+	if len(selection) > 0 && g.justPressed(ActionEditCopy) {
+		g.copyBodies(selection)
+		fmt.Printf("Copied %d bodies\n", len(selection))
+	}
+
+	var cmd EditCommand
+	if g.justPressed(ActionEditPaste) {
+		clip := g.pasteClipboard()
+		if clip == nil {
+			return false
+		}
+		cursor := g.camera.ScreenToWorld(g.input.MousePosition())
+		cmd = g.pasteCommand("Paste", clip, cursor.Sub(clip.center()))
+	} else if len(selection) > 0 && g.justPressed(ActionEditDuplicate) {
+		cmd = g.pasteCommand("Duplicate", newClipboardJson(selection), duplicateOffset)
+	}
+	if cmd == nil {
+		return false
+	}
+	g.selectBodies(g.editHistory.Execute(g, cmd))
+	return true
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// ControlsState lists every action with its bindings and lets the player pick
+// new ones
+type ControlsState struct {
+	menu    *Menu
+	waiting bool // The next button pressed becomes the binding of the selected action
+	message string
+}
+
+func (state *ControlsState) Init(g *Game) {
+	g.showHud = false
+	state.menu = NewMenu(g, "Controls", nil)
+	state.refresh(g)
+}
+
+func (state *ControlsState) refresh(g *Game) {
+	var items []string
+	for i, info := range actionList {
+		item := fmt.Sprintf("%s: %s", info.description, g.key(info.action))
+		if state.waiting && i == state.menu.Selected {
+			item = fmt.Sprintf("%s: press a button", info.description)
+		} else if hasConflict(g.actions, info.action) {
+			item += " (!)"
+		}
+		items = append(items, item)
+	}
+	items = append(items, "Reset to defaults", "Back")
+	state.menu.SetItems(g, items)
+
+	switch {
+	case state.waiting:
+		state.menu.SetHint("Press the new button, Escape to cancel")
+	case state.message != "":
+		state.menu.SetHint(state.message)
+	default:
+		state.menu.SetHint(g.menuHint() + ", " + g.key(ActionConfirm) + " to rebind")
+	}
+}
+
+func (state *ControlsState) Update(g *Game) {
+	if state.waiting {
+		state.updateWaiting(g)
+		return
+	}
+
+	chosen := state.menu.Update(g)
+	switch {
+	case chosen >= 0 && chosen < len(actionList):
+		state.waiting = true
+		state.refresh(g)
+	case chosen == len(actionList):
+		g.actions = g.baseActions.Clone()
+		g.saveBindings()
+		state.message = "Controls reset to defaults"
+		state.refresh(g)
+	case chosen == len(actionList)+1 || g.justPressed(ActionBack):
+		g.states.Pop()
+		g.states.Push(&SettingsState{})
+	}
+}
+
+// updateWaiting reads the new binding. Escape always cancels, whatever Back is
+// bound to, so the player can't lock themselves in.
+func (state *ControlsState) updateWaiting(g *Game) {
+	if g.input.JustPressed(pixelgl.KeyEscape) {
+		state.waiting = false
+		state.message = ""
+		state.refresh(g)
+		return
+	}
+
+	binding, ok := pressedBinding(g.input)
+	if !ok {
+		return
+	}
+	action := actionList[state.menu.Selected].action
+	swapped := g.actions.Rebind(action, binding)
+	g.saveBindings()
+
+	state.waiting = false
+	state.message = ""
+	if len(swapped) > 0 {
+		var names []string
+		for _, other := range swapped {
+			info, _ := findAction(other)
+			names = append(names, info.description)
+		}
+		state.message = fmt.Sprintf("%s was in use, swapped with %s", binding, strings.Join(names, ", "))
+	}
+	state.refresh(g)
+}
+
+// pressedBinding is the button pressed this frame along with the held modifiers
+func pressedBinding(in InputSource) (Binding, bool) {
+	for _, b := range allButtons() {
+		if isModifierKey(b) || !in.JustPressed(b) {
+			continue
+		}
+		binding := Binding{Button: b}
+		if in.Pressed(pixelgl.KeyLeftControl) || in.Pressed(pixelgl.KeyRightControl) {
+			binding.Mods |= ModCtrl
+		}
+		if in.Pressed(pixelgl.KeyLeftShift) || in.Pressed(pixelgl.KeyRightShift) {
+			binding.Mods |= ModShift
+		}
+		if in.Pressed(pixelgl.KeyLeftAlt) || in.Pressed(pixelgl.KeyRightAlt) {
+			binding.Mods |= ModAlt
+		}
+		return binding, true
+	}
+	return Binding{}, false
+}
+
+func isModifierKey(b pixelgl.Button) bool {
+	switch b {
+	case pixelgl.KeyLeftControl, pixelgl.KeyRightControl, pixelgl.KeyLeftShift, pixelgl.KeyRightShift,
+		pixelgl.KeyLeftAlt, pixelgl.KeyRightAlt, pixelgl.KeyLeftSuper, pixelgl.KeyRightSuper:
+		return true
+	}
+	return false
+}
+
+func hasConflict(m *ActionMap, action Action) bool {
+	for _, b := range m.Bindings(action) {
+		if len(m.Conflicts(action, b)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (state *ControlsState) Render(g *Game) {
+	state.menu.Render(g)
+}
+
+// saveBindings stores the player's changes to the configured bindings in the profile
+func (g *Game) saveBindings() {
+	g.profile.Settings.Bindings = g.actions.Diff(g.baseActions)
+	g.saveProfile()
+}
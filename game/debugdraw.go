@@ -0,0 +1,149 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+const debugVelocityScale = 0.5 // Seconds of travel drawn for velocity vectors
+
+var (
+	debugAwakeColor   = colornames.Magenta
+	debugSleepColor   = colornames.Gray
+	debugContactColor = colornames.Red
+	debugNormalColor  = colornames.Orangered
+	debugJointColor   = colornames.Teal
+	debugCenterColor  = colornames.Black
+	debugVelColor     = colornames.Gold
+)
+
+// anchoredJoint is implemented by every box2d joint type we create
+type anchoredJoint interface {
+	GetAnchorA() box2d.B2Vec2
+	GetAnchorB() box2d.B2Vec2
+}
+
+// drawDebug draws what box2d sees, walking the world lists rather than our
+// own bodies so nothing the simulation knows about is missed.
+func (g *Game) drawDebug(imd *imdraw.IMDraw) {
+	cam := g.camera
+	project := func(v box2d.B2Vec2) pixel.Vec {
+		return cam.WorldToScreen(*toPixelVec(&v))
+	}
+
+	imd.SetMatrix(pixel.IM)
+	g.debugText.Clear()
+
+	labels := g.debugLabels()
+	for b := g.World.GetBodyList(); b != nil; b = b.GetNext() {
+		bodyColor := debugAwakeColor
+		if !b.IsAwake() {
+			bodyColor = debugSleepColor
+		}
+
+		// Fixture AABBs
+		xf := b.GetTransform()
+		for f := b.GetFixtureList(); f != nil; f = f.GetNext() {
+			aabb := box2d.B2AABB{}
+			f.GetShape().ComputeAABB(&aabb, xf, 0)
+			imd.Color = bodyColor
+			imd.Push(project(aabb.LowerBound), project(aabb.UpperBound))
+			imd.Rectangle(1)
+		}
+
+		// Center of mass
+		center := b.GetWorldCenter()
+		c := project(center)
+		imd.Color = debugCenterColor
+		imd.Push(c.Add(pixel.V(-4, 0)), c.Add(pixel.V(4, 0)))
+		imd.Line(1)
+		imd.Push(c.Add(pixel.V(0, -4)), c.Add(pixel.V(0, 4)))
+		imd.Line(1)
+
+		// Linear velocity
+		vel := b.GetLinearVelocity()
+		if vel.LengthSquared() > 0 {
+			tip := box2d.B2Vec2Add(center, box2d.B2Vec2MulScalar(debugVelocityScale, vel))
+			imd.Color = debugVelColor
+			imd.Push(c, project(tip))
+			imd.Line(2)
+		}
+
+		// Body ID and sleep state
+		g.debugText.Dot = c.Add(pixel.V(6, 6))
+		if b.IsAwake() {
+			fmt.Fprint(g.debugText, labels[b])
+		} else {
+			fmt.Fprintf(g.debugText, "%s zz", labels[b])
+		}
+	}
+
+	// Contact points and normals
+	for contact := g.World.GetContactList(); contact != nil; contact = contact.GetNext() {
+		if !contact.IsTouching() {
+			continue
+		}
+		manifold := box2d.MakeB2WorldManifold()
+		contact.GetWorldManifold(&manifold)
+		for i := 0; i < contact.GetManifold().PointCount; i++ {
+			p := manifold.Points[i]
+			imd.Color = debugContactColor
+			imd.Push(project(p))
+			imd.Circle(3, 0)
+
+			tip := box2d.B2Vec2Add(p, box2d.B2Vec2MulScalar(0.3, manifold.Normal))
+			imd.Color = debugNormalColor
+			imd.Push(project(p), project(tip))
+			imd.Line(1)
+		}
+	}
+
+	// Joint anchors, connected to their bodies
+	for j := g.World.GetJointList(); j != nil; j = j.GetNext() {
+		joint, ok := j.(anchoredJoint)
+		if !ok {
+			continue
+		}
+		a := project(joint.GetAnchorA())
+		b := project(joint.GetAnchorB())
+		imd.Color = debugJointColor
+		imd.Push(project(j.GetBodyA().GetPosition()), a)
+		imd.Line(1)
+		imd.Push(a, b)
+		imd.Line(2)
+		imd.Push(b, project(j.GetBodyB().GetPosition()))
+		imd.Line(1)
+		imd.Push(a)
+		imd.Circle(4, 1)
+		imd.Push(b)
+		imd.Circle(4, 1)
+	}
+
+	g.debugText.Draw(g.Window, pixel.IM)
+}
+
+// debugLabels names the bodies of the world the way the editor refers to them,
+// by their index in the level bodies or the cargo rather than by where box2d
+// keeps them in its list, which changes as bodies are created and deleted.
+func (g *Game) debugLabels() map[*box2d.B2Body]string {
+	labels := map[*box2d.B2Body]string{g.worldAnchor: "world"}
+	for i, b := range g.Bodies {
+		labels[b.Body] = fmt.Sprintf("#%d", i)
+	}
+	for i, b := range g.CargoBodies {
+		labels[b.Body] = fmt.Sprintf("cargo #%d", i)
+	}
+	for _, e := range levelEntities {
+		if b := g.entityBody(e); b != nil {
+			labels[b.Body] = entityNames[e]
+		}
+	}
+	for i, b := range g.car.wheels {
+		labels[b.Body] = fmt.Sprintf("wheel #%d", i)
+	}
+	return labels
+}
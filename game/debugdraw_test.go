@@ -0,0 +1,37 @@
+package game
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Bodies are labelled by their place in the level, as the editor refers to
+// them, however box2d orders its list
+func TestDebugLabels(t *testing.T) {
+	g := newTestGame(t)
+	startLevel(g, g.config.Levels[0], true)
+	// A new body goes to the front of box2d's list and the end of the level's
+	run(g, append(tap(pixelgl.KeyN), click(emptySpace(g))...)...)
+
+	labels := g.debugLabels()
+	for i, b := range g.Bodies {
+		if got, want := labels[b.Body], fmt.Sprintf("#%d", i); got != want {
+			t.Errorf("body %d labelled %q, want %q", i, got, want)
+		}
+	}
+	for i, b := range g.CargoBodies {
+		if got, want := labels[b.Body], fmt.Sprintf("cargo #%d", i); got != want {
+			t.Errorf("cargo %d labelled %q, want %q", i, got, want)
+		}
+	}
+	if got := labels[g.ground.Body]; got != "ground" {
+		t.Errorf("ground labelled %q", got)
+	}
+	for b := g.World.GetBodyList(); b != nil; b = b.GetNext() {
+		if labels[b] == "" {
+			t.Errorf("body at %v has no label", b.GetPosition())
+		}
+	}
+}
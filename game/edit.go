@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"math"
 
-	"github.com/faiface/pixel/pixelgl"
+	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
 )
 
 type EditModeStateStack struct {
@@ -43,29 +45,57 @@ type EditModeState interface {
 type MainEditState struct{}
 type PlacementState struct{}
 type SelectedState struct {
-	gBody *GameBody
+	bodies []*GameBody
+	change bodyChange // Move or rotation in progress
+	drag   *gizmoDrag
+}
+
+// BoxSelectState drags a rectangle to select every body it touches
+type BoxSelectState struct {
+	start    pixel.Vec // World position the drag started at
+	end      pixel.Vec
+	additive bool // Keep the bodies selected before
+}
+
+// editRenderer is implemented by edit states that draw something of their own
+type editRenderer interface {
+	Render(g *Game)
 }
 
 func (state *MainEditState) Update(g *Game) {
 	fmt.Println("MainState")
+	if handleEditHistory(g) || handleEditClipboard(g, nil) {
+		return
+	}
 
-	if g.Window.JustPressed(pixelgl.MouseButton1) {
-		body := HandleEditModeSelect(g)
-		if body != nil {
-			g.editStates.Pop()
-			g.editStates.Push(&SelectedState{gBody: body})
-			return
-		}
+	// Esc with nothing selected leaves the editor
+	if g.justPressed(ActionBack) {
+		g.confirmLeave(func(g *Game) {
+			g.leaveEditor()
+			g.states.Pop()
+			g.states.Push(&MainMenuState{})
+		})
+		return
+	}
+
+	if g.justPressed(ActionEditSelect) {
+		handleEditSelect(g)
+		return
+	}
+
+	if g.justPressed(ActionEditNewJoint) {
+		g.startJointTool()
+		return
 	}
 
 	// Press N to create new body
-	if g.Window.JustPressed(pixelgl.KeyN) {
+	if g.justPressed(ActionEditNewBody) {
 		handleEditCreateNew(g)
 		g.editStates.Pop()
 		g.editStates.Push(&PlacementState{})
 	}
 	// Press N to create new cargo body
-	if g.Window.JustPressed(pixelgl.KeyC) {
+	if g.justPressed(ActionEditNewCargo) {
 		handleEditCreateNewCargo(g)
 		g.editStates.Pop()
 		g.editStates.Push(&PlacementState{})
@@ -77,25 +107,24 @@ func (state *PlacementState) Update(g *Game) {
 	handleEditShape(g)
 
 	// Press V to to swap between Box and Ball
-	if g.Window.JustPressed(pixelgl.KeyV) {
+	if g.justPressed(ActionEditSwapShape) {
 		handleEditSwapShape(g)
 	}
 
 	// Add new body to world
-	if g.Window.JustPressed(pixelgl.MouseButton1) {
-		g.newBody.Body.GetFixtureList().SetSensor(false)
-		if g.newBody.IsCargo {
-			g.CargoBodies = append(g.CargoBodies, g.newBody)
-		} else {
-			g.Bodies = append(g.Bodies, g.newBody)
-		}
+	if g.justPressed(ActionEditSelect) {
+		g.newBody.Body.GetFixtureList().SetSensor(g.newBody.IsSensor)
+		list := g.bodyList(g.newBody.IsCargo)
+		*list = append(*list, g.newBody)
+		ref := bodyRef{Cargo: g.newBody.IsCargo, Index: len(*list) - 1}
+		g.editHistory.Record(&createBodyCommand{ref: ref, body: newBodyJson(g.newBody)})
 		g.newBody = nil
 		g.editStates.Pop()
 		g.editStates.Push(&MainEditState{})
 	}
 
 	// Press Esc to cancel body placement
-	if g.Window.JustPressed(pixelgl.KeyEscape) && g.newBody != nil {
+	if g.justPressed(ActionBack) && g.newBody != nil {
 		g.World.DestroyBody(g.newBody.Body)
 		g.newBody = nil
 		g.editStates.Pop()
@@ -105,70 +134,270 @@ func (state *PlacementState) Update(g *Game) {
 
 func (state *SelectedState) Update(g *Game) {
 	fmt.Println("SelectedState")
-	// Delete body
-	if g.Window.JustPressed(pixelgl.KeyDelete) {
+	if state.drag != nil {
+		if g.pressed(ActionEditSelect) {
+			state.drag.update(g, state.bodies)
+			return
+		}
+		state.drag.change.End(g)
+		state.drag = nil
+	}
+
+	moving := false
+	for _, action := range []Action{ActionEditMoveLeft, ActionEditMoveRight, ActionEditMoveUp, ActionEditMoveDown, ActionEditRotateLeft, ActionEditRotateRight} {
+		moving = moving || g.pressed(action)
+	}
+	if moving {
+		state.change.Begin(g, state.bodies)
+	} else {
+		state.change.End(g)
+	}
+
+	bodies := g.levelBodies(state.bodies)
+	if handleEditHistory(g) || handleEditClipboard(g, bodies) {
+		return
+	}
+
+	// Delete the selection, keeping the entities selected
+	if g.justPressed(ActionEditDelete) && len(bodies) > 0 {
 		fmt.Println("Delete")
-		index := 0
-		for i := 0; i < len(g.Bodies); i++ {
-			if g.Bodies[i].IsSelected {
-				index = i
-				g.World.DestroyBody(g.Bodies[i].Body)
-				g.Bodies = append(g.Bodies[:index], g.Bodies[index+1:]...)
-				break
-			}
+		state.change.End(g)
+		g.editHistory.Execute(g, newDeleteCommand(g, bodies))
+		g.selectBodies(g.selection())
+		return
+	}
+
+	if move := moveInput(g, state.bodies[0]); move != pixel.ZV {
+		moveBodies(state.bodies, move)
+	}
+	if turn := rotateInput(g, state.bodies[0].Body.GetAngle()); turn != 0 && g.canRotate(state.bodies) {
+		rotateBodies(state.bodies, turn)
+	}
+
+	handleEditProperties(g, bodies)
+
+	if g.justPressed(ActionEditNewJoint) {
+		state.change.End(g)
+		g.startJointTool()
+		return
+	}
+
+	// Press F to frame the selection
+	if g.justPressed(ActionEditFrameSelection) {
+		g.camera.Frame(unionBounds(state.bodies), cameraFrameMargin)
+	}
+
+	if g.justPressed(ActionEditSelect) {
+		state.change.End(g)
+		mouse := g.input.MousePosition()
+		if handle, ok := g.handleAt(state.bodies, mouse); ok {
+			state.drag = g.beginDrag(state.bodies, handle)
+		} else if body := pickBody(g, mouse); body != nil && body.IsSelected && !shiftHeld(g.input) {
+			state.drag = g.beginDrag(state.bodies, gizmoHandle{kind: gizmoMove})
+		} else {
+			handleEditSelect(g)
 		}
+	}
+}
 
-		for i := 0; i < len(g.CargoBodies); i++ {
-			if g.CargoBodies[i].IsSelected {
-				index = i
-				g.World.DestroyBody(g.CargoBodies[i].Body)
-				g.CargoBodies = append(g.CargoBodies[:index], g.CargoBodies[index+1:]...)
-				break
-			}
+func (state *SelectedState) Render(g *Game) {
+	g.renderGizmos(state.bodies)
+}
+
+// moveInput is how far the move keys move body this frame. Without grid
+// snapping bodies glide while the keys are held, with it they jump from grid
+// line to grid line.
+func moveInput(g *Game, body *GameBody) pixel.Vec {
+	pos := body.Body.GetPosition()
+	keys := []struct {
+		action Action
+		dir    pixel.Vec
+	}{
+		{ActionEditMoveRight, pixel.V(1, 0)},
+		{ActionEditMoveLeft, pixel.V(-1, 0)},
+		{ActionEditMoveUp, pixel.V(0, 1)},
+		{ActionEditMoveDown, pixel.V(0, -1)},
+	}
+	move := pixel.ZV
+	for _, key := range keys {
+		step, stepped := g.profile.Settings.Snap.moveStep(pixel.V(pos.X, pos.Y), key.dir)
+		if stepped && g.repeated(key.action) || !stepped && g.pressed(key.action) {
+			move = move.Add(step)
+		}
+	}
+	return move
+}
+
+// rotateInput is how far the rotate keys turn something at angle this frame,
+// by angle steps when angle snapping is on
+func rotateInput(g *Game, angle float64) float64 {
+	keys := []struct {
+		action Action
+		dir    float64
+	}{
+		{ActionEditRotateLeft, 1},
+		{ActionEditRotateRight, -1},
+	}
+	for _, key := range keys {
+		step, stepped := g.profile.Settings.Snap.rotateStep(angle, key.dir)
+		if stepped && g.repeated(key.action) || !stepped && g.pressed(key.action) {
+			return step
 		}
+	}
+	return 0
+}
+
+func moveBodies(bodies []*GameBody, move pixel.Vec) {
+	for _, body := range bodies {
+		pos := body.Body.GetPosition()
+		body.Body.SetTransform(box2d.B2Vec2{X: pos.X + move.X, Y: pos.Y + move.Y}, body.Body.GetAngle())
+	}
+}
+
+// rotateBodies turns bodies by angle around their common center, which stays
+// in place however often it is rotated
+func rotateBodies(bodies []*GameBody, angle float64) {
+	center := box2d.B2Vec2{}
+	for _, body := range bodies {
+		center = box2d.B2Vec2Add(center, body.Body.GetPosition())
+	}
+	center = box2d.B2Vec2MulScalar(1/float64(len(bodies)), center)
+
+	rot := box2d.MakeB2RotFromAngle(angle)
+	for _, body := range bodies {
+		offset := box2d.B2RotVec2Mul(rot, box2d.B2Vec2Sub(body.Body.GetPosition(), center))
+		body.Body.SetTransform(box2d.B2Vec2Add(center, offset), body.Body.GetAngle()+angle)
+	}
+}
+
+// handleEditProperties changes the density and friction of every selected body
+func handleEditProperties(g *Game, bodies []*GameBody) {
+	density, friction := 0.0, 0.0
+	switch {
+	case g.justPressed(ActionEditLessDensity):
+		density = -0.1
+	case g.justPressed(ActionEditMoreDensity):
+		density = 0.1
+	case g.justPressed(ActionEditLessFriction):
+		friction = -0.1
+	case g.justPressed(ActionEditMoreFriction):
+		friction = 0.1
+	default:
+		return
+	}
+
+	change := bodyChange{}
+	change.Begin(g, bodies)
+	for _, body := range bodies {
+		body.Density = math.Max(0, body.Density+density)
+		body.Friction = math.Max(0, body.Friction+friction)
+		rebuildFixture(body)
+	}
+	change.End(g)
+}
+
+// handleEditSelect selects the joint or body under the cursor, starting to
+// drag a body, or starts a box selection on empty space. With shift held
+// bodies are added to the selection or taken out of it.
+func handleEditSelect(g *Game) {
+	mouse := g.input.MousePosition()
+	additive := shiftHeld(g.input)
+	if joint := g.jointAt(mouse); joint != nil && !additive {
+		g.selectJoint(joint)
+		return
+	}
+	body := pickBody(g, mouse)
+	if body == nil {
+		start := g.camera.ScreenToWorld(mouse)
 		g.editStates.Pop()
-		g.editStates.Push(&MainEditState{})
+		g.editStates.Push(&BoxSelectState{start: start, end: start, additive: additive})
+		return
 	}
 
-	if g.Window.Pressed(pixelgl.KeyRight) {
-		p := state.gBody.Body.GetPosition()
-		p.X += 0.01
-		state.gBody.Body.SetTransform(p, state.gBody.Body.GetAngle())
-	}
-	if g.Window.Pressed(pixelgl.KeyLeft) {
-		p := state.gBody.Body.GetPosition()
-		p.X -= 0.01
-		state.gBody.Body.SetTransform(p, state.gBody.Body.GetAngle())
-	}
-	if g.Window.Pressed(pixelgl.KeyUp) {
-		p := state.gBody.Body.GetPosition()
-		p.Y += 0.01
-		state.gBody.Body.SetTransform(p, state.gBody.Body.GetAngle())
-	}
-	if g.Window.Pressed(pixelgl.KeyDown) {
-		p := state.gBody.Body.GetPosition()
-		p.Y -= 0.01
-		state.gBody.Body.SetTransform(p, state.gBody.Body.GetAngle())
-	}
-	if g.Window.Pressed(pixelgl.KeyComma) {
-		p := state.gBody.Body.GetPosition()
-		a := state.gBody.Body.GetAngle()
-		a += math.Pi / 160
-		state.gBody.Body.SetTransform(p, a)
-	}
-	if g.Window.Pressed(pixelgl.KeyPeriod) {
-		p := state.gBody.Body.GetPosition()
-		a := state.gBody.Body.GetAngle()
-		a -= math.Pi / 160
-		state.gBody.Body.SetTransform(p, a)
-	}
-
-	if g.Window.JustPressed(pixelgl.MouseButton1) {
-		body := HandleEditModeSelect(g)
-		state.gBody = body
-		if body == nil {
-			g.editStates.Pop()
-			g.editStates.Push(&MainEditState{})
+	if !additive {
+		g.selectBodies([]*GameBody{body})
+		if state, ok := g.editStates.Top().(*SelectedState); ok {
+			state.drag = g.beginDrag(state.bodies, gizmoHandle{kind: gizmoMove})
 		}
+		return
+	}
+	var selected []*GameBody
+	for _, b := range g.selection() {
+		if b != body {
+			selected = append(selected, b)
+		}
+	}
+	if !body.IsSelected {
+		selected = append(selected, body)
+	}
+	g.selectBodies(selected)
+}
+
+// pickBody is the level body under a screen position, or else the entity
+func pickBody(g *Game, pos pixel.Vec) *GameBody {
+	worldPos := screenToWorld(pos, g.camera)
+	for _, cargo := range []bool{false, true} {
+		for _, body := range *g.bodyList(cargo) {
+			if body.Body.GetFixtureList().TestPoint(worldPos) {
+				return body
+			}
+		}
+	}
+	return g.pickEntity(worldPos)
+}
+
+func (state *BoxSelectState) Update(g *Game) {
+	state.end = g.camera.ScreenToWorld(g.input.MousePosition())
+	if g.pressed(ActionEditSelect) {
+		return
+	}
+
+	rect := pixel.Rect{Min: state.start, Max: state.end}.Norm()
+	var selected []*GameBody
+	if state.additive {
+		selected = g.selection()
+	}
+	for _, cargo := range []bool{false, true} {
+		for _, body := range *g.bodyList(cargo) {
+			if !body.IsSelected && rect.Intersects(bodyBounds(body)) {
+				selected = append(selected, body)
+			}
+		}
+	}
+	g.selectBodies(selected)
+}
+
+func (state *BoxSelectState) Render(g *Game) {
+	imd := g.imDraw
+	imd.SetMatrix(pixel.IM)
+	imd.Color = colornames.Darkorange
+	imd.Push(g.camera.WorldToScreen(state.start), g.camera.WorldToScreen(state.end))
+	imd.Rectangle(2)
+}
+
+func handleEditCamera(g *Game) {
+	// Pan with A and D or by dragging with the middle mouse button
+	if g.pressed(ActionEditPanRight) {
+		g.camera.Pos.X += 0.1 / g.camera.Zoom
+	}
+	if g.pressed(ActionEditPanLeft) {
+		g.camera.Pos.X -= 0.1 / g.camera.Zoom
+	}
+	if g.pressed(ActionEditPanDrag) {
+		g.camera.Pan(g.input.MousePreviousPosition(), g.input.MousePosition())
+	}
+
+	// Zoom around the cursor
+	scroll := g.input.MouseScroll().Y
+	if scroll != 0 {
+		g.camera.ZoomAt(g.input.MousePosition(), math.Pow(cameraZoomStep, scroll))
+	}
+
+	// Press Home to frame the whole level
+	if g.justPressed(ActionEditFrameAll) {
+		bodies := append([]*GameBody{g.ground, g.goalBody}, g.Bodies...)
+		bodies = append(bodies, g.CargoBodies...)
+		bodies = append(bodies, g.car.Bodies()...)
+		g.camera.Frame(unionBounds(bodies), cameraFrameMargin)
 	}
 }
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// drag holds the left mouse button from one point to another
+func drag(from, to pixel.Vec, buttons ...pixelgl.Button) []InputFrame {
+	held := append([]pixelgl.Button{pixelgl.MouseButtonLeft}, buttons...)
+	return []InputFrame{
+		{Mouse: from, Pressed: held},
+		{Mouse: to, Pressed: held},
+		{Mouse: to, Pressed: buttons},
+	}
+}
+
+func TestBoxSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		selected []int // Indexes in g.Bodies selected before the drag
+		shift    bool
+		empty    bool // Drag over empty space only
+		want     string
+		bodies   []int // Indexes in g.Bodies selected after it
+	}{
+		{name: "touched", want: "*game.SelectedState", bodies: []int{0}},
+		{name: "replaces", selected: []int{1}, want: "*game.SelectedState", bodies: []int{0}},
+		{name: "shift adds", selected: []int{1}, shift: true, want: "*game.SelectedState", bodies: []int{0, 1}},
+		{name: "nothing", empty: true, want: "*game.MainEditState"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			startLevel(g, g.config.Levels[0], true)
+			if tt.selected != nil {
+				var bodies []*GameBody
+				for _, i := range tt.selected {
+					bodies = append(bodies, g.Bodies[i])
+				}
+				g.selectBodies(bodies)
+			}
+			pos := g.Bodies[0].Body.GetPosition()
+			from := g.camera.WorldToScreen(pixel.V(pos.X, pos.Y+30))
+			to := centerOnScreen(g, g.Bodies[0])
+			if tt.empty {
+				to = from.Add(pixel.V(40, 40))
+			}
+			var shift []pixelgl.Button
+			if tt.shift {
+				shift = []pixelgl.Button{pixelgl.KeyLeftShift}
+			}
+			frames := drag(from, to, shift...)
+
+			run(g, frames[:2]...)
+			if got := stateName(g.editStates.Top()); got != "*game.BoxSelectState" {
+				t.Fatalf("edit state %s while dragging, want *game.BoxSelectState", got)
+			}
+			run(g, frames[2:]...)
+			if got := stateName(g.editStates.Top()); got != tt.want {
+				t.Fatalf("edit state %s, want %s", got, tt.want)
+			}
+			for _, i := range tt.bodies {
+				if !g.Bodies[i].IsSelected {
+					t.Errorf("body %d not selected", i)
+				}
+			}
+			if !tt.shift && tt.selected != nil && g.Bodies[tt.selected[0]].IsSelected {
+				t.Errorf("body %d still selected", tt.selected[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package game
+
+import "github.com/bytearena/box2d"
+
+// levelEntity is a part of every level that isn't one of its bodies: the
+// ground, the goal and where the car starts. In the editor they are selected,
+// moved and resized like bodies, but they can't be deleted, copied or turned.
+type levelEntity int
+
+const (
+	entityNone levelEntity = iota
+	entityGround
+	entityGoal
+	entitySpawn
+)
+
+var levelEntities = []levelEntity{entityGround, entityGoal, entitySpawn}
+
+var entityNames = map[levelEntity]string{
+	entityGround: "ground",
+	entityGoal:   "goal",
+	entitySpawn:  "car spawn",
+}
+
+// entityBody is the body standing in for an entity. The chassis of the car
+// stands in for the spawn.
+func (g *Game) entityBody(e levelEntity) *GameBody {
+	switch e {
+	case entityGround:
+		return g.ground
+	case entityGoal:
+		return g.goalBody
+	case entitySpawn:
+		return g.car.body
+	}
+	return nil
+}
+
+// entityOf is the entity body stands in for, or entityNone for a level body
+func (g *Game) entityOf(body *GameBody) levelEntity {
+	for _, e := range levelEntities {
+		if g.entityBody(e) == body {
+			return e
+		}
+	}
+	return entityNone
+}
+
+// levelBodies leaves the entities out of bodies
+func (g *Game) levelBodies(bodies []*GameBody) []*GameBody {
+	var level []*GameBody
+	for _, b := range bodies {
+		if g.entityOf(b) == entityNone {
+			level = append(level, b)
+		}
+	}
+	return level
+}
+
+// canRotate reports whether bodies can be turned, which entities can't
+func (g *Game) canRotate(bodies []*GameBody) bool {
+	return len(g.levelBodies(bodies)) == len(bodies)
+}
+
+// pickEntity is the entity body at a world position. Clicking a wheel picks
+// the car.
+func (g *Game) pickEntity(pos box2d.B2Vec2) *GameBody {
+	if g.goalBody.Body.GetFixtureList().TestPoint(pos) {
+		return g.goalBody
+	}
+	for _, b := range g.car.Bodies() {
+		if b.Body.GetFixtureList().TestPoint(pos) {
+			return g.car.body
+		}
+	}
+	if g.ground.Body.GetFixtureList().TestPoint(pos) {
+		return g.ground
+	}
+	return nil
+}
+
+// placeCar makes the spawn follow the chassis while it is edited, bringing
+// the wheels along. The spawn is only edited while it is selected, which it
+// also is after undoing or redoing a change of it. Otherwise it stays where
+// the level put it, wherever the chassis is.
+func (g *Game) placeCar() {
+	if !g.car.body.IsSelected {
+		return
+	}
+	g.car.spawn = g.car.body.Body.GetPosition()
+	g.car.Reset()
+}
+
+// writeEntities stores where the entities are in data
+func (g *Game) writeEntities(data *LevelData) {
+	ground := g.ground.Body.GetPosition()
+	friction := g.ground.Friction
+	data.Ground = &GroundJson{X: ground.X, Y: ground.Y, Length: g.ground.HalfW * 2, Height: g.ground.HalfH * 2, Friction: &friction}
+	goal := g.goalBody.Body.GetPosition()
+	data.Goal = &GoalJson{X: goal.X, Y: goal.Y, Width: g.goalBody.HalfW * 2, Height: g.goalBody.HalfH * 2}
+	spawn := g.car.spawn
+	data.Spawn = &SpawnJson{X: spawn.X, Y: spawn.Y, FacingLeft: g.car.facing < 0}
+}
+
+// turnCarCommand makes the car face the other way. Doing it twice undoes it.
+type turnCarCommand struct{}
+
+func (c *turnCarCommand) Do(g *Game) []*GameBody {
+	g.turnCar()
+	return []*GameBody{g.car.body}
+}
+
+func (c *turnCarCommand) Undo(g *Game) []*GameBody {
+	return c.Do(g)
+}
+
+func (c *turnCarCommand) String() string {
+	return "Turn car around"
+}
+
+// turnCar builds the car again facing the other way
+func (g *Game) turnCar() {
+	old := g.car
+	spawn := old.spawn
+	DestroyCar(old, g.World)
+	g.car = CreateCar(g.World, old.def, SpawnJson{X: spawn.X, Y: spawn.Y, FacingLeft: old.facing > 0})
+}
@@ -0,0 +1,418 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/bytearena/box2d"
+)
+
+const (
+	evolveChassisVertices = 8
+	evolvePopulation      = 24
+	evolveEliteCount      = 2
+	evolveTournamentSize  = 3
+	evolveMutationRate    = 0.2
+	evolveMinWheels       = 2
+	evolveMaxWheels       = 4
+	evolveMaxSteps        = 60 * 45 // 45 seconds of simulated driving
+	evolveStallSteps      = 60 * 4  // Give up when the car has not moved forward in 4 seconds
+	evolveStepsPerFrame   = 600
+	evolveExportFile      = "evolvedVehicle.json"
+)
+
+// genome is the evolvable encoding of a vehicle. The chassis is a set of radii
+// around the origin and every wheel sits on one of the chassis vertices.
+type genome struct {
+	radii        [evolveChassisVertices]float64
+	wheelVertex  []int
+	wheelRadius  []float64
+	wheelDensity []float64
+	density      float64
+	torque       float64
+}
+
+type Candidate struct {
+	Vehicle     *VehicleDef
+	Distance    float64
+	Cargo       int
+	TotalCargo  int
+	ReachedGoal bool
+	Fitness     float64
+	genome      genome
+}
+
+// Evolver runs a genetic algorithm over vehicle designs. Candidates are
+// simulated headlessly in their own box2d world on the chosen level.
+type Evolver struct {
+	level      *LevelData
+	rng        *rand.Rand
+	Generation int
+	Population []*Candidate
+	Best       *Candidate // Best candidate of the last finished generation
+	BestEver   *Candidate
+	evaluated  int
+	trial      *vehicleTrial
+}
+
+// vehicleTrial is a resumable headless run of one vehicle on a level
+type vehicleTrial struct {
+	world    *box2d.B2World
+	car      *Car
+	cargo    []*GameBody
+	joints   []*GameJoint
+	goalX    float64
+	steps    int
+	best     float64 // Furthest the car got from the spawn
+	stall    int
+	finished bool
+	reached  bool
+}
+
+func NewEvolver(level *LevelData, seed int64) *Evolver {
+	e := &Evolver{level: level, rng: rand.New(rand.NewSource(seed)), Generation: 1}
+	for i := 0; i < evolvePopulation; i++ {
+		e.Population = append(e.Population, newCandidate(e.randomGenome()))
+	}
+	return e
+}
+
+func newCandidate(gen genome) *Candidate {
+	return &Candidate{Vehicle: gen.toVehicle(), genome: gen}
+}
+
+// Advance simulates up to steps physics steps of the current candidate and
+// returns true when that completed a generation.
+func (e *Evolver) Advance(steps int) bool {
+	candidate := e.Population[e.evaluated]
+	if e.trial == nil {
+		e.trial = newVehicleTrial(e.level, candidate.Vehicle)
+	}
+	if !e.trial.advance(steps) {
+		return false
+	}
+
+	e.trial.score(candidate)
+	e.trial = nil
+	e.evaluated++
+	if e.evaluated < len(e.Population) {
+		return false
+	}
+
+	e.nextGeneration()
+	return true
+}
+
+// Evaluated returns how many candidates of the current generation are done
+func (e *Evolver) Evaluated() int {
+	return e.evaluated
+}
+
+func (e *Evolver) nextGeneration() {
+	sort.Slice(e.Population, func(i, j int) bool {
+		return e.Population[i].Fitness > e.Population[j].Fitness
+	})
+	e.Best = e.Population[0]
+	if e.BestEver == nil || e.Best.Fitness > e.BestEver.Fitness {
+		e.BestEver = e.Best
+	}
+
+	next := []*Candidate{}
+	for i := 0; i < evolveEliteCount && i < len(e.Population); i++ {
+		next = append(next, newCandidate(e.Population[i].genome))
+	}
+	for len(next) < evolvePopulation {
+		a := e.tournament()
+		b := e.tournament()
+		child := e.crossover(a.genome, b.genome)
+		e.mutate(&child)
+		next = append(next, newCandidate(child))
+	}
+
+	e.Population = next
+	e.evaluated = 0
+	e.Generation++
+}
+
+func (e *Evolver) tournament() *Candidate {
+	best := e.Population[e.rng.Intn(len(e.Population))]
+	for i := 1; i < evolveTournamentSize; i++ {
+		c := e.Population[e.rng.Intn(len(e.Population))]
+		if c.Fitness > best.Fitness {
+			best = c
+		}
+	}
+	return best
+}
+
+func (e *Evolver) randomGenome() genome {
+	gen := genome{}
+	for i := 0; i < evolveChassisVertices; i++ {
+		gen.radii[i] = randRange(e.rng, 0.2, 1.5)
+	}
+	wheels := evolveMinWheels + e.rng.Intn(evolveMaxWheels-evolveMinWheels+1)
+	for i := 0; i < wheels; i++ {
+		gen.wheelVertex = append(gen.wheelVertex, e.rng.Intn(evolveChassisVertices))
+		gen.wheelRadius = append(gen.wheelRadius, randRange(e.rng, 0.15, 0.6))
+		gen.wheelDensity = append(gen.wheelDensity, randRange(e.rng, 0.2, 3.0))
+	}
+	gen.density = randRange(e.rng, 0.2, 2.0)
+	gen.torque = randRange(e.rng, 0.5, 6.0)
+	return gen
+}
+
+func (e *Evolver) crossover(a, b genome) genome {
+	child := genome{density: a.density, torque: a.torque}
+	if e.rng.Intn(2) == 0 {
+		child.density = b.density
+	}
+	if e.rng.Intn(2) == 0 {
+		child.torque = b.torque
+	}
+	for i := 0; i < evolveChassisVertices; i++ {
+		child.radii[i] = a.radii[i]
+		if e.rng.Intn(2) == 0 {
+			child.radii[i] = b.radii[i]
+		}
+	}
+
+	// Wheel count comes from one parent, each wheel from whichever parent has it
+	wheels := len(a.wheelVertex)
+	if e.rng.Intn(2) == 0 {
+		wheels = len(b.wheelVertex)
+	}
+	for i := 0; i < wheels; i++ {
+		parent := a
+		if i >= len(a.wheelVertex) || (i < len(b.wheelVertex) && e.rng.Intn(2) == 0) {
+			parent = b
+		}
+		child.wheelVertex = append(child.wheelVertex, parent.wheelVertex[i])
+		child.wheelRadius = append(child.wheelRadius, parent.wheelRadius[i])
+		child.wheelDensity = append(child.wheelDensity, parent.wheelDensity[i])
+	}
+	return child
+}
+
+func (e *Evolver) mutate(gen *genome) {
+	for i := 0; i < evolveChassisVertices; i++ {
+		gen.radii[i] = e.mutateValue(gen.radii[i], 0.2, 0.2, 1.5)
+	}
+	for i := 0; i < len(gen.wheelVertex); i++ {
+		if e.rng.Float64() < evolveMutationRate {
+			gen.wheelVertex[i] = e.rng.Intn(evolveChassisVertices)
+		}
+		gen.wheelRadius[i] = e.mutateValue(gen.wheelRadius[i], 0.1, 0.15, 0.6)
+		gen.wheelDensity[i] = e.mutateValue(gen.wheelDensity[i], 0.3, 0.2, 3.0)
+	}
+	gen.density = e.mutateValue(gen.density, 0.2, 0.2, 2.0)
+	gen.torque = e.mutateValue(gen.torque, 0.5, 0.5, 6.0)
+
+	// Occasionally gain or lose a wheel
+	if e.rng.Float64() < evolveMutationRate/2 {
+		if len(gen.wheelVertex) < evolveMaxWheels && e.rng.Intn(2) == 0 {
+			gen.wheelVertex = append(gen.wheelVertex, e.rng.Intn(evolveChassisVertices))
+			gen.wheelRadius = append(gen.wheelRadius, randRange(e.rng, 0.15, 0.6))
+			gen.wheelDensity = append(gen.wheelDensity, randRange(e.rng, 0.2, 3.0))
+		} else if len(gen.wheelVertex) > evolveMinWheels {
+			last := len(gen.wheelVertex) - 1
+			gen.wheelVertex = gen.wheelVertex[:last]
+			gen.wheelRadius = gen.wheelRadius[:last]
+			gen.wheelDensity = gen.wheelDensity[:last]
+		}
+	}
+}
+
+func (e *Evolver) mutateValue(v, sigma, min, max float64) float64 {
+	if e.rng.Float64() >= evolveMutationRate {
+		return v
+	}
+	return math.Max(min, math.Min(max, v+e.rng.NormFloat64()*sigma))
+}
+
+func randRange(rng *rand.Rand, min, max float64) float64 {
+	return min + rng.Float64()*(max-min)
+}
+
+func (gen genome) toVehicle() *VehicleDef {
+	def := DefaultVehicle()
+	def.Name = "Evolved car"
+	def.Density = gen.density
+	def.MotorTorque = gen.torque
+
+	def.Chassis = nil
+	for i := 0; i < evolveChassisVertices; i++ {
+		angle := float64(i) * 2 * math.Pi / evolveChassisVertices
+		def.Chassis = append(def.Chassis, box2d.B2Vec2{X: gen.radii[i] * math.Cos(angle), Y: gen.radii[i] * math.Sin(angle)})
+	}
+
+	def.Wheels = nil
+	for i := 0; i < len(gen.wheelVertex); i++ {
+		v := def.Chassis[gen.wheelVertex[i]]
+		def.Wheels = append(def.Wheels, WheelDef{X: v.X, Y: v.Y, Radius: gen.wheelRadius[i], Density: gen.wheelDensity[i], Friction: 1.0, Texture: "wheel.png"})
+	}
+	return def
+}
+
+// EvaluateVehicle drives a vehicle on a level without rendering and reports
+// how far it got and how much of the cargo it kept.
+func EvaluateVehicle(level *LevelData, def *VehicleDef) *Candidate {
+	trial := newVehicleTrial(level, def)
+	trial.advance(evolveMaxSteps)
+	candidate := &Candidate{Vehicle: def}
+	trial.score(candidate)
+	return candidate
+}
+
+func newVehicleTrial(level *LevelData, def *VehicleDef) *vehicleTrial {
+	world := box2d.MakeB2World(Gravity)
+	_, goal := CreateGroundAndGoal(&world, level.ground(), level.goal())
+	bodies := CreateBodies(&world, level.Bodies)
+	spawn := level.spawn()
+	car := CreateCar(&world, def, spawn)
+	cargo := CreateBodies(&world, cargoOnVehicle(level.Cargo, def, spawn))
+	joints := CreateJoints(&world, newWorldAnchor(&world), bodies, cargo, level.Joints)
+	car.Forward()
+
+	return &vehicleTrial{world: &world, car: car, cargo: cargo, joints: joints, goalX: goal.Body.GetPosition().X}
+}
+
+// cargoOnVehicle lifts the level cargo so it rests on top of the chassis
+func cargoOnVehicle(cargo []BodyJson, def *VehicleDef, spawn SpawnJson) []BodyJson {
+	top := spawn.Y
+	for i := 0; i < len(def.Chassis); i++ {
+		top = math.Max(top, spawn.Y+def.Chassis[i].Y)
+	}
+
+	lift := 0.0
+	for i := 0; i < len(cargo); i++ {
+		bottom := cargo[i].Y - cargo[i].Hy
+		if cargo[i].BodyShape == Circle {
+			bottom = cargo[i].Y - cargo[i].Radius
+		}
+		lift = math.Max(lift, top+0.05-bottom)
+	}
+
+	lifted := make([]BodyJson, len(cargo))
+	for i := 0; i < len(cargo); i++ {
+		lifted[i] = cargo[i]
+		lifted[i].Y += lift
+	}
+	return lifted
+}
+
+func (t *vehicleTrial) advance(steps int) bool {
+	for i := 0; i < steps && !t.finished; i++ {
+		t.world.Step(TimeStep, VelocityIterations, PositionIterations)
+		breakJoints(t.world, t.joints)
+		t.steps++
+
+		distance := t.car.ahead(t.car.body.Body.GetPosition().X, t.car.spawn.X)
+		if distance > t.best+0.05 {
+			t.best = distance
+			t.stall = 0
+		} else {
+			t.stall++
+		}
+
+		if carPastGoal(t.car, t.goalX) {
+			t.reached = true
+			t.finished = true
+		} else if t.stall > evolveStallSteps || t.steps >= evolveMaxSteps {
+			t.finished = true
+		}
+	}
+	return t.finished
+}
+
+func (t *vehicleTrial) score(c *Candidate) {
+	carPos := t.car.body.Body.GetPosition()
+	c.Distance = t.best
+	c.ReachedGoal = t.reached
+	c.TotalCargo = len(t.cargo)
+	c.Cargo = 0
+	for i := 0; i < len(t.cargo); i++ {
+		// Cargo that kept up with the car counts as retained
+		if t.car.ahead(t.cargo[i].Body.GetPosition().X, carPos.X) > -t.car.body.HalfW-0.5 {
+			c.Cargo++
+		}
+	}
+
+	c.Fitness = c.Distance
+	if c.ReachedGoal {
+		// Reaching the goal sooner is better
+		c.Fitness += 20 + float64(evolveMaxSteps-t.steps)*TimeStep
+	}
+	if c.TotalCargo > 0 {
+		c.Fitness *= 0.25 + 0.75*float64(c.Cargo)/float64(c.TotalCargo)
+	}
+}
+
+func (state *EvolveState) Init(g *Game) {
+	g.text.Clear()
+	fmt.Fprintln(g.text, "Evolving vehicles")
+
+	g.sideText.Clear()
+	fmt.Fprintf(g.sideText, "%s to drive the best design\n", g.key(ActionConfirm))
+	fmt.Fprintf(g.sideText, "%s to export the best design\n", g.key(ActionExportVehicle))
+	fmt.Fprintf(g.sideText, "%s to go back\n", g.key(ActionBack))
+}
+
+func (state *EvolveState) Update(g *Game) {
+	if state.evolver.Advance(evolveStepsPerFrame) {
+		// Show off the best of the generation that just finished
+		g.replaceCar(state.evolver.Best.Vehicle)
+	}
+
+	if state.evolver.Best != nil {
+		g.car.Forward()
+		g.stepWorld()
+	}
+
+	g.followCar()
+
+	g.infoText.Clear()
+	fmt.Fprintf(g.infoText, "Generation: %d\n", state.evolver.Generation)
+	fmt.Fprintf(g.infoText, "Candidate: %d/%d\n", state.evolver.Evaluated()+1, evolvePopulation)
+	if best := state.evolver.Best; best != nil {
+		fmt.Fprintf(g.infoText, "Best distance: %.1f\n", best.Distance)
+		fmt.Fprintf(g.infoText, "Cargo kept: %d/%d\n", best.Cargo, best.TotalCargo)
+		fmt.Fprintf(g.infoText, "Wheels: %d\n", len(best.Vehicle.Wheels))
+		fmt.Fprintf(g.infoText, "Torque: %.1f\n", best.Vehicle.MotorTorque)
+		if best.ReachedGoal {
+			fmt.Fprintln(g.infoText, "Reached the goal!")
+		}
+	}
+	if state.message != "" {
+		fmt.Fprintln(g.infoText, state.message)
+	}
+
+	if g.justPressed(ActionExportVehicle) && state.evolver.BestEver != nil {
+		if err := SaveVehicle(state.evolver.BestEver.Vehicle, evolveExportFile); err != nil {
+			state.message = fmt.Sprintf("Export failed: %v", err)
+		} else {
+			state.message = "Exported to " + evolveExportFile
+		}
+	}
+
+	if g.justPressed(ActionConfirm) && state.evolver.BestEver != nil {
+		g.vehicle = state.evolver.BestEver.Vehicle
+		g.replaceCar(g.vehicle)
+		g.states.Pop()
+		g.states.Push(PlayState{})
+		return
+	}
+
+	if g.justPressed(ActionBack) {
+		g.replaceCar(g.vehicle)
+		g.states.Pop()
+		g.states.Push(PlayState{})
+	}
+}
+
+func (state *EvolveState) Render(g *Game) {
+	g.text.Draw(g.Window)
+	g.sideText.Draw(g.Window)
+	g.infoText.Draw(g.Window)
+}
@@ -0,0 +1,91 @@
+package game
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/faiface/pixel/text"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	FontRegular = "regular"
+	FontBold    = "bold"
+	FontMono    = "mono"
+	FontDir     = "./resources/fonts"
+)
+
+type fontKey struct {
+	name string
+	size float64
+}
+
+// FontRegistry holds TrueType fonts by name and the atlases rendered from them
+type FontRegistry struct {
+	fonts   map[string]*truetype.Font
+	atlases map[fontKey]*text.Atlas
+	basic   *text.Atlas
+}
+
+// NewFontRegistry registers the bundled Go fonts and every .ttf file in dir
+func NewFontRegistry(dir string) *FontRegistry {
+	r := &FontRegistry{fonts: map[string]*truetype.Font{}, atlases: map[fontKey]*text.Atlas{}}
+	r.basic = text.NewAtlas(basicfont.Face7x13, text.ASCII)
+
+	builtin := map[string][]byte{FontRegular: goregular.TTF, FontBold: gobold.TTF, FontMono: gomono.TTF}
+	for name, ttf := range builtin {
+		if err := r.Register(name, ttf); err != nil {
+			fmt.Println("Warning: could not parse font", name+":", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.ttf"))
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if err := r.Load(name, file); err != nil {
+			fmt.Println("Warning: could not load font", file+":", err)
+		}
+	}
+	return r
+}
+
+func (r *FontRegistry) Register(name string, ttf []byte) error {
+	f, err := truetype.Parse(ttf)
+	if err != nil {
+		return err
+	}
+	r.fonts[name] = f
+	return nil
+}
+
+func (r *FontRegistry) Load(name, path string) error {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return r.Register(name, bytes)
+}
+
+// Atlas returns the glyph atlas for a font at a size in pixels. Unknown fonts
+// fall back to the basic bitmap font.
+func (r *FontRegistry) Atlas(name string, size float64) *text.Atlas {
+	key := fontKey{name, size}
+	if atlas, ok := r.atlases[key]; ok {
+		return atlas
+	}
+	f, ok := r.fonts[name]
+	if !ok {
+		return r.basic
+	}
+	face := truetype.NewFace(f, &truetype.Options{Size: size, Hinting: font.HintingFull, GlyphCacheEntries: 1})
+	atlas := text.NewAtlas(face, text.ASCII)
+	r.atlases[key] = atlas
+	return atlas
+}
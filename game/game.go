@@ -2,8 +2,7 @@ package game
 
 import (
 	"fmt"
-	"image/color"
-	"math"
+	"time"
 
 	"github.com/bytearena/box2d"
 	"github.com/faiface/pixel"
@@ -11,7 +10,6 @@ import (
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
 	"golang.org/x/image/colornames"
-	"golang.org/x/image/font/basicfont"
 )
 
 type PlayerTurn int
@@ -25,6 +23,8 @@ const TimeStep = 1.0 / 60.0
 const VelocityIterations = 8
 const PositionIterations = 3
 
+var Gravity = box2d.B2Vec2{X: 0.0, Y: -3.0}
+
 const (
 	ScreenWidth          = 1200
 	ScreenHeight         = 900
@@ -37,6 +37,7 @@ type Shape int
 const (
 	Rectangle Shape = 0
 	Circle    Shape = 1
+	Polygon   Shape = 2
 )
 
 type BoxDef struct {
@@ -50,6 +51,16 @@ type BoxDef struct {
 	bodyType uint8
 }
 
+type PolygonDef struct {
+	x        float64
+	y        float64
+	vertices []box2d.B2Vec2
+	density  float64
+	friction float64
+	isSensor bool
+	bodyType uint8
+}
+
 type BallDef struct {
 	x        float64
 	y        float64
@@ -67,244 +78,387 @@ type PlaceMode int
 
 type Game struct {
 	World        *box2d.B2World
+	Profiler     *Profiler
 	Window       *pixelgl.Window
+	input        InputSource // Where all game logic reads the keyboard and mouse
 	imDraw       *imdraw.IMDraw
 	camera       *Camera
 	ground       *GameBody
 	Bodies       []*GameBody
 	CargoBodies  []*GameBody
+	Joints       []*GameJoint
+	worldAnchor  *box2d.B2Body // Holds joints to the world
 	score        int
 	forceDrag    *ForceDrag
 	EditMode     bool
 	isDragging   bool
 	toggleGrid   bool
+	toggleDebug  bool
+	toggleStats  bool
+	showHud      bool
 	car          *Car
+	vehicle      *VehicleDef
+	assets       *AssetManager
 	groundSprite *pixel.Sprite
+	backgrounds  []*Background
 	newBody      *GameBody
-	text         *text.Text
-	sideText     *text.Text
-	infoText     *text.Text
-	finishedText *text.Text
-	startText    *text.Text
-	scoreText    *text.Text
+	fonts        *FontRegistry
+	text         *Label
+	sideText     *Label
+	infoText     *Label
+	finishedText *Label
+	startText    *Label
+	scoreText    *Label
+	progressText *text.Text
+	debugText    *text.Text
+	statsText    *text.Text
 	goalBody     *GameBody
 	states       GameStateStack
 	editStates   EditModeStateStack
+	editHistory  EditHistory
+	clipboard    *ClipboardJson // Bodies copied in the editor
+	inspector    Inspector
+	editLevel    LevelInfo // File the editor opens and saves to
+	editMessage  string    // Outcome of the last save, shown in the editor
+	closing      bool      // Unsaved changes were dealt with, the window may close
+	playTest     *PlayTest // The editor, while its level is played
 	config       *ConfigData
 	levelData    *LevelData
 	levelInfo    *LevelInfo
 	levelIndex   int
+	replay       *Replay // Inputs since the level was started
+	leaderboards *LeaderboardStore
+	actions      *ActionMap // Bindings in use
+	baseActions  *ActionMap // Bindings before the profile's own changes
+	profiles     *ProfileStore
+	profile      *Profile
+	lastPlaytime time.Time
 	placeMode    PlaceMode
 }
 
-type Camera struct {
-	X float64
-	Y float64
-}
-
 type GameBody struct {
-	Body       *box2d.B2Body
-	HalfW      float64
-	HalfH      float64
-	Radius     float64
-	Density    float64
-	Friction   float64
-	Shape      Shape
-	IsSelected bool
-	IsCargo    bool
+	Body        *box2d.B2Body
+	HalfW       float64
+	HalfH       float64
+	Radius      float64
+	Vertices    []box2d.B2Vec2
+	Density     float64
+	Friction    float64
+	Restitution float64
+	Shape       Shape
+	Texture     *BodyTexture
+	IsSelected  bool
+	IsCargo     bool
+	IsSensor    bool // Other bodies pass through
 }
 
 type Car struct {
 	carAcc      float64
+	def         *VehicleDef
+	spawn       box2d.B2Vec2
+	facing      float64 // 1 when driving to the right, -1 when driving left
 	body        *GameBody
-	wheel1      *GameBody
-	wheel2      *GameBody
-	wheelJoint1 *box2d.B2WheelJoint
-	wheelJoint2 *box2d.B2WheelJoint
+	wheels      []*GameBody
+	wheelJoints []*box2d.B2WheelJoint
 }
 
 type ForceDrag struct {
 	body     *box2d.B2Body
 	localPos *box2d.B2Vec2
+	target   int // Index into forceTargets, for the replay
 }
 
 func (car *Car) Forward() {
-	car.carAcc = -20.0
-	car.wheel1.Body.SetAngularDamping(0.0)
-	car.wheel2.Body.SetAngularDamping(0.0)
-	car.wheelJoint1.EnableMotor(true)
-	car.wheelJoint1.SetMotorSpeed(car.carAcc)
-	car.wheelJoint2.EnableMotor(true)
-	car.wheelJoint2.SetMotorSpeed(car.carAcc)
+	car.carAcc = -car.def.MotorSpeed * car.facing
+	for i := 0; i < len(car.wheels); i++ {
+		car.wheels[i].Body.SetAngularDamping(0.0)
+		car.wheelJoints[i].EnableMotor(true)
+		car.wheelJoints[i].SetMotorSpeed(car.carAcc)
+	}
 }
 
 func (car *Car) Backwards() {
-	car.carAcc = 20.0
-	car.wheel1.Body.SetAngularDamping(0.0)
-	car.wheel2.Body.SetAngularDamping(0.0)
-	car.wheelJoint1.EnableMotor(true)
-	car.wheelJoint1.SetMotorSpeed(car.carAcc)
-	car.wheelJoint2.EnableMotor(true)
-	car.wheelJoint2.SetMotorSpeed(car.carAcc)
+	car.carAcc = car.def.MotorSpeed * car.facing
+	for i := 0; i < len(car.wheels); i++ {
+		car.wheels[i].Body.SetAngularDamping(0.0)
+		car.wheelJoints[i].EnableMotor(true)
+		car.wheelJoints[i].SetMotorSpeed(car.carAcc)
+	}
 }
 
 func (car *Car) Stop() {
 	car.carAcc = 0.0
-	car.wheel1.Body.SetAngularDamping(1.0)
-	car.wheel2.Body.SetAngularDamping(1.0)
-	car.wheelJoint1.EnableMotor(false)
-	car.wheelJoint1.SetMotorSpeed(car.carAcc)
-	car.wheelJoint2.EnableMotor(false)
-	car.wheelJoint2.SetMotorSpeed(car.carAcc)
+	for i := 0; i < len(car.wheels); i++ {
+		car.wheels[i].Body.SetAngularDamping(1.0)
+		car.wheelJoints[i].EnableMotor(false)
+		car.wheelJoints[i].SetMotorSpeed(car.carAcc)
+	}
 }
 
 func (car *Car) Break() {
 	car.carAcc = 0.0
-	car.wheel1.Body.SetAngularDamping(1.0)
-	car.wheel2.Body.SetAngularDamping(1.0)
-	car.wheelJoint1.EnableMotor(true)
-	car.wheelJoint1.SetMotorSpeed(-car.wheelJoint1.GetJointAngularSpeed())
-	car.wheelJoint2.EnableMotor(true)
-	car.wheelJoint2.SetMotorSpeed(-car.wheelJoint2.GetJointAngularSpeed())
-
-	// angularVel := car.wheel1.GetAngularVelocity()
-	// fmt.Println(car.wheelJoint1.GetJointAngularSpeed())
-}
-
-func (g *Game) resetCar() {
-	g.car.body.Body.SetTransform(box2d.B2Vec2{X: 3.5, Y: 1.5}, 0)
-	g.car.wheel1.Body.SetTransform(box2d.B2Vec2{X: 2.7, Y: 1.3}, 0)
-	g.car.wheel2.Body.SetTransform(box2d.B2Vec2{X: 4.3, Y: 1.5}, 0)
-	g.car.body.Body.SetLinearVelocity(box2d.B2Vec2{X: 0, Y: 0})
-	g.car.body.Body.SetAngularVelocity(0)
-	g.car.wheel1.Body.SetLinearVelocity(box2d.B2Vec2{X: 0, Y: 0})
-	g.car.wheel1.Body.SetAngularVelocity(0)
-	g.car.wheel2.Body.SetLinearVelocity(box2d.B2Vec2{X: 0, Y: 0})
-	g.car.wheel2.Body.SetAngularVelocity(0)
-}
-
-func (g *Game) Initialize(win *pixelgl.Window, imd *imdraw.IMDraw) {
+	for i := 0; i < len(car.wheels); i++ {
+		car.wheels[i].Body.SetAngularDamping(1.0)
+		car.wheelJoints[i].EnableMotor(true)
+		car.wheelJoints[i].SetMotorSpeed(-car.wheelJoints[i].GetJointAngularSpeed())
+	}
+}
+
+// Apply drives the car with the controls held during one step
+func (car *Car) Apply(controls Controls) {
+	if controls&ControlBackward == 0 {
+		car.Stop()
+	} else {
+		car.Backwards()
+	}
+	if controls&ControlForward != 0 {
+		car.Forward()
+	}
+	if controls&ControlBrake != 0 {
+		car.Break()
+	}
+	if controls&ControlReset != 0 {
+		car.Reset()
+	}
+}
+
+// Bodies returns the chassis followed by all wheels
+func (car *Car) Bodies() []*GameBody {
+	bodies := []*GameBody{car.body}
+	return append(bodies, car.wheels...)
+}
+
+// Reset puts the car back at its spawn point, standing still
+func (car *Car) Reset() {
+	car.body.Body.SetTransform(car.spawn, 0)
+	car.body.Body.SetLinearVelocity(box2d.B2Vec2{X: 0, Y: 0})
+	car.body.Body.SetAngularVelocity(0)
+	for i := 0; i < len(car.wheels); i++ {
+		wheel := car.def.Wheels[i]
+		pos := box2d.B2Vec2{X: car.spawn.X + wheel.X*car.facing, Y: car.spawn.Y + wheel.Y}
+		car.wheels[i].Body.SetTransform(pos, 0)
+		car.wheels[i].Body.SetLinearVelocity(box2d.B2Vec2{X: 0, Y: 0})
+		car.wheels[i].Body.SetAngularVelocity(0)
+	}
+}
+
+// Initialize sets up the game for win. Without a window the game runs headless
+// and reads its input from whatever SetInput is given. It fails when there is
+// no usable config to take the levels from.
+func (g *Game) Initialize(win *pixelgl.Window, imd *imdraw.IMDraw) error {
 	g.Window = win
+	if win != nil {
+		g.input = win
+	}
 	g.imDraw = imd
 	g.states.game = g
-	g.camera = &Camera{}
-
-	basicAtlas := text.NewAtlas(basicfont.Face7x13, text.ASCII)
-	basicTxt := text.New(pixel.V(100, 870), basicAtlas)
-	basicTxt.Color = colornames.Black
-	g.text = basicTxt
-	fmt.Fprintln(basicTxt, "")
-
-	sideTxt := text.New(pixel.V(980, 800), basicAtlas)
-	sideTxt.Color = colornames.Black
-	g.sideText = sideTxt
-
-	infoTxt := text.New(pixel.V(980, 600), basicAtlas)
-	infoTxt.Color = colornames.Black
-	g.infoText = infoTxt
-	fmt.Fprintln(infoTxt, "")
-
-	finishedTxt := text.New(pixel.V(160, 780), basicAtlas)
-	finishedTxt.Color = colornames.Black
-	g.finishedText = finishedTxt
-	fmt.Fprintln(finishedTxt, "Congrats you reached the goal")
-
-	startTxt := text.New(pixel.V(140, 730), basicAtlas)
-	startTxt.Color = color.RGBA{0, 0, 0, 255}
-	g.startText = startTxt
-	fmt.Fprintln(startTxt, "Carry the payload to the finish line")
-
-	g.scoreText = text.New(pixel.V(380, 860), basicAtlas)
-	g.scoreText.Color = colornames.Black
+	g.camera = NewCamera()
+
+	g.fonts = NewFontRegistry(FontDir)
+	g.text = NewLabel(g.fonts, TitleStyle, AnchorTopLeft, pixel.V(20, 20))
+
+	g.sideText = NewLabel(g.fonts, BodyStyle, AnchorTopRight, pixel.V(minimapMargin, 140))
+	g.sideText.Width = minimapWidth
+
+	g.infoText = NewLabel(g.fonts, BodyStyle, AnchorTopRight, pixel.V(minimapMargin, 330))
+	g.infoText.Width = minimapWidth
+
+	g.finishedText = NewLabel(g.fonts, HeadingStyle, AnchorTop, pixel.V(0, 120))
+	fmt.Fprintln(g.finishedText, "Congrats you reached the goal")
+
+	g.startText = NewLabel(g.fonts, HeadingStyle, AnchorTop, pixel.V(0, 170))
+	fmt.Fprintln(g.startText, "Carry the payload to the finish line")
+
+	g.scoreText = NewLabel(g.fonts, ScoreStyle, AnchorTop, pixel.V(0, 20))
 	fmt.Fprintf(g.scoreText, "Score: %d", g.score)
 
+	smallAtlas := g.fonts.Atlas(FontRegular, 13)
+	g.progressText = text.New(pixel.ZV, smallAtlas)
+	g.progressText.Color = colornames.Black
+
+	g.debugText = text.New(pixel.ZV, smallAtlas)
+	g.debugText.Color = colornames.Black
+
+	g.Profiler = &Profiler{}
+	g.statsText = text.New(pixel.ZV, smallAtlas)
+	g.statsText.Color = colornames.Black
+
 	// Create world
-	world := box2d.MakeB2World(box2d.B2Vec2{X: 0.0, Y: -3.0})
+	world := box2d.MakeB2World(Gravity)
 	g.World = &world
 
-	path := "./resources/grassLongPlatform.png"
-	picture, err := loadPicture(path)
-	if err != nil {
-		panic(err)
+	g.assets = NewAssetManager(AssetRoot)
+	if picture := g.assets.Picture("grassLongPlatform.png"); picture != nil {
+		g.groundSprite = pixel.NewSprite(picture, pixel.R(0, 0, 300, 100))
 	}
 
-	sprite := pixel.NewSprite(picture, pixel.R(0, 0, 300, 100))
-	g.groundSprite = sprite
+	config, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	g.config = config
+	g.editLevel = defaultEditLevel
+	g.vehicle = DefaultVehicle()
+	if g.config.Vehicle != "" {
+		vehicle, err := LoadVehicle(g.config.Vehicle)
+		if err != nil {
+			fmt.Println("Loading vehicle failed, driving the stock car:", err)
+		} else {
+			g.vehicle = vehicle
+		}
+	}
+	g.loadProfile()
+	leaderboards, err := NewLeaderboardStore()
+	if err != nil {
+		fmt.Println("Leaderboards unavailable:", err)
+	}
+	g.leaderboards = leaderboards
 
-	// Load first level
-	g.config = LoadConfig()
-	g.states.Push(LoadingState{levelInfo: g.config.Levels[0]})
+	// Show the first level behind the main menu
+	g.loadLevel(g.config.Levels[0])
+	g.states.Push(&MainMenuState{})
+	return nil
 }
 
 func (g *Game) Update(win *pixelgl.Window) error {
+	start := time.Now()
+	defer func() { g.Profiler.Add(SectionUpdate, time.Since(start)) }()
+
 	g.scoreText.Clear()
 	fmt.Fprintf(g.scoreText, "Score: %d", g.score)
+	g.trackPlaytime()
+	// Follow the window when it is resized
+	g.camera.Screen = g.screenBounds()
 
 	g.states.Top().Update(g)
 
-	handleInput(g, win)
+	inputStart := time.Now()
+	handleInput(g)
+	g.Profiler.Add(SectionInput, time.Since(inputStart))
 
 	return nil
 }
 
 func (g *Game) checkGoal() bool {
-	goalPos := g.goalBody.Body.GetPosition()
-	carPos := g.car.body.Body.GetPosition()
+	return carPastGoal(g.car, g.goalBody.Body.GetPosition().X)
+}
+
+func carPastGoal(car *Car, goalX float64) bool {
+	carPos := car.body.Body.GetPosition()
 
 	// Back of car and a bit extra
-	if carPos.X-g.car.body.HalfW-0.3 > goalPos.X {
-		return true
-	}
-	return false
+	return car.ahead(carPos.X-car.facing*(car.body.HalfW+0.3), goalX) > 0
+}
+
+// ahead is how far x is past from in the direction the car drives
+func (car *Car) ahead(x, from float64) float64 {
+	return (x - from) * car.facing
+}
+
+// replaceCar swaps the car for one built from def and restarts the level
+func (g *Game) replaceCar(def *VehicleDef) {
+	g.buildWorld(def)
+}
+
+// buildWorld fills a fresh world with the loaded level and a car built from
+// vehicle. Starting from an empty world every time keeps runs reproducible, so
+// the replay recorded from here on can be simulated again. The camera bounds
+// follow the level, which may have been edited since it was loaded.
+func (g *Game) buildWorld(vehicle *VehicleDef) *LevelWorld {
+	level := NewLevelWorld(g.levelData, vehicle)
+	g.World = level.World
+	g.ground = level.Ground
+	g.goalBody = level.Goal
+	g.car = level.Car
+	g.Bodies = level.Bodies
+	g.CargoBodies = level.Cargo
+	g.Joints = level.Joints
+	g.worldAnchor = level.Anchor
+	g.forceDrag = nil
+	g.isDragging = false
+	g.replay = NewReplay(g.levelInfo.Filename, vehicle)
+	g.camera.Bounds = levelBounds(g)
+	// Edits refer to the bodies of the old world
+	g.editHistory.Clear()
+	return level
 }
 
 func (g *Game) CalcScore() int {
+	return calcScore(g.CargoBodies, g.car, g.goalBody.Body.GetPosition().X)
+}
+
+// calcScore rewards every piece of cargo past the goal by its size
+func calcScore(cargo []*GameBody, car *Car, goalX float64) int {
 	score := 0
-	goalPos := g.goalBody.Body.GetPosition()
-	for i := 0; i < len(g.CargoBodies); i++ {
-		body := g.CargoBodies[i]
+	for i := 0; i < len(cargo); i++ {
+		body := cargo[i]
 		pos := body.Body.GetPosition()
-		if pos.X > goalPos.X {
+		if car.ahead(pos.X, goalX) > 0 {
 			score += int(4000 * body.HalfW * body.HalfH)
 		}
 	}
 	return score
 }
 
-func handleInput(g *Game, win *pixelgl.Window) {
-	if win.JustPressed(pixelgl.KeyM) {
+func handleInput(g *Game) {
+	// Keys typed into text aren't shortcuts
+	if g.typingText() {
+		return
+	}
+	if g.justPressed(ActionToggleGrid) {
 		g.toggleGrid = !g.toggleGrid
 	}
+	if g.justPressed(ActionToggleDebug) {
+		g.toggleDebug = !g.toggleDebug
+	}
+	if g.justPressed(ActionToggleStats) {
+		g.toggleStats = !g.toggleStats
+	}
+	if g.justPressed(ActionToggleFullscreen) && g.Window != nil {
+		g.toggleFullscreen()
+	}
+	if g.justPressed(ActionToggleRecording) {
+		var err error
+		if g.Profiler.Recording() {
+			err = g.Profiler.StopRecording()
+		} else {
+			err = g.Profiler.StartRecording()
+		}
+		if err != nil {
+			fmt.Println("Profile recording failed:", err)
+		}
+	}
 }
 
-func checkCollision(body *box2d.B2Body, pos pixel.Vec, cam *Camera) bool {
-	worldPos := screenToWorld(pos, cam)
-	return body.GetFixtureList().TestPoint(worldPos)
+// typingText reports whether typed keys go into text, like a prompt, the name
+// of a new profile or a value in the inspector
+func (g *Game) typingText() bool {
+	switch state := g.states.Top().(type) {
+	case *PromptState:
+		return state.Choices == nil
+	case *ProfileState:
+		return state.typing
+	case EditState:
+		return g.inspector.editing
+	}
+	return false
 }
 
-func HandleEditModeSelect(g *Game) *GameBody {
-	// Select body we clicked on
-	pos := g.Window.MousePosition()
-	bodies := append(g.Bodies[:], g.CargoBodies...)
-
-	for i := 0; i < len(bodies); i++ {
-		bodies[i].IsSelected = false
-	}
+// stepWorld advances the physics one time step
+func (g *Game) stepWorld() {
+	start := time.Now()
+	g.World.Step(TimeStep, VelocityIterations, PositionIterations)
+	breakJoints(g.World, g.Joints)
+	g.Profiler.Add(SectionStep, time.Since(start))
+}
 
-	for i := 0; i < len(bodies); i++ {
-		worldPos := box2d.B2Vec2{X: pos.X / Scale, Y: pos.Y / Scale}
-		worldPos.X = worldPos.X + g.camera.X
-		collided := bodies[i].Body.GetFixtureList().TestPoint(worldPos)
-		if collided {
-			bodies[i].IsSelected = true
-			return bodies[i]
-		}
-	}
-	return nil
+func checkCollision(body *box2d.B2Body, pos pixel.Vec, cam *Camera) bool {
+	worldPos := screenToWorld(pos, cam)
+	return body.GetFixtureList().TestPoint(worldPos)
 }
 
 func handleEditCreateNew(g *Game) *GameBody {
-	mousePos := g.Window.MousePosition()
+	mousePos := g.input.MousePosition()
 	worldPos := screenToWorld(mousePos, g.camera)
 
 	fmt.Println(g.placeMode)
@@ -327,7 +481,7 @@ func handleEditCreateNew(g *Game) *GameBody {
 }
 
 func handleEditCreateNewCargo(g *Game) *GameBody {
-	mousePos := g.Window.MousePosition()
+	mousePos := g.input.MousePosition()
 	worldPos := screenToWorld(mousePos, g.camera)
 	boxDef := BoxDef{x: worldPos.X, y: worldPos.Y, hx: 0.2, hy: 0.2, density: 1.0, friction: 1.0, isSensor: true}
 	boxDef.bodyType = box2d.B2BodyType.B2_dynamicBody
@@ -342,59 +496,48 @@ func handleEditCreateNewCargo(g *Game) *GameBody {
 
 func handleEditShape(g *Game) {
 	needNewShape := false
-	if g.placeMode == BoxMode {
-		if g.Window.JustPressed(pixelgl.KeyRight) && g.newBody.HalfW+0.1 > 0 {
+	if g.newBody.Shape == Rectangle {
+		if g.justPressed(ActionEditGrowWidth) && g.newBody.HalfW+0.1 > 0 {
 			g.newBody.HalfW += 0.1
 			needNewShape = true
-		} else if g.Window.JustPressed(pixelgl.KeyLeft) && g.newBody.HalfW-0.1 > 0 {
+		} else if g.justPressed(ActionEditShrinkWidth) && g.newBody.HalfW-0.1 > 0 {
 			g.newBody.HalfW -= 0.1
 			needNewShape = true
-		} else if g.Window.JustPressed(pixelgl.KeyUp) && g.newBody.HalfH+0.1 > 0 {
+		} else if g.justPressed(ActionEditGrowHeight) && g.newBody.HalfH+0.1 > 0 {
 			g.newBody.HalfH += 0.1
 			needNewShape = true
-		} else if g.Window.JustPressed(pixelgl.KeyDown) && g.newBody.HalfH-0.1 > 0 {
+		} else if g.justPressed(ActionEditShrinkHeight) && g.newBody.HalfH-0.1 > 0 {
 			g.newBody.HalfH -= 0.1
 			needNewShape = true
 		}
 	} else {
-		if g.Window.JustPressed(pixelgl.KeyRight) && g.newBody.Radius+0.1 > 0 {
+		if g.justPressed(ActionEditGrowWidth) && g.newBody.Radius+0.1 > 0 {
 			g.newBody.Radius += 0.1
 			needNewShape = true
-		} else if g.Window.JustPressed(pixelgl.KeyLeft) && g.newBody.Radius+0.1 > 0 {
+		} else if g.justPressed(ActionEditShrinkWidth) && g.newBody.Radius-0.1 > 0 {
 			g.newBody.Radius -= 0.1
 			needNewShape = true
 		}
 	}
-	if g.Window.Pressed(pixelgl.KeyPeriod) {
-		angle := g.newBody.Body.GetAngle() - math.Pi/160
+	if turn := rotateInput(g, g.newBody.Body.GetAngle()); turn != 0 {
+		angle := g.newBody.Body.GetAngle() + turn
 		g.newBody.Body.SetTransform(g.newBody.Body.GetPosition(), angle)
-	} else if g.Window.Pressed(pixelgl.KeyComma) {
-		angle := g.newBody.Body.GetAngle() + math.Pi/160
-		g.newBody.Body.SetTransform(g.newBody.Body.GetPosition(), angle)
-	} else if g.Window.JustPressed(pixelgl.KeyR) {
+	} else if g.justPressed(ActionEditLessDensity) {
 		g.newBody.Density -= 0.1
 		needNewShape = true
-	} else if g.Window.JustPressed(pixelgl.KeyT) {
+	} else if g.justPressed(ActionEditMoreDensity) {
 		g.newBody.Density += 0.1
 		needNewShape = true
-	} else if g.Window.JustPressed(pixelgl.KeyF) {
+	} else if g.justPressed(ActionEditLessFriction) {
 		g.newBody.Friction -= 0.1
 		needNewShape = true
-	} else if g.Window.JustPressed(pixelgl.KeyG) {
+	} else if g.justPressed(ActionEditMoreFriction) {
 		g.newBody.Friction += 0.1
 		needNewShape = true
 	}
 
 	if needNewShape {
-		shape := g.newBody.Body.GetFixtureList().GetShape()
-		shape.Destroy()
-		if g.placeMode == BoxMode {
-			def := createBoxFixureDef(g.newBody.HalfW, g.newBody.HalfH, g.newBody.Density, g.newBody.Friction, true)
-			g.newBody.Body.CreateFixtureFromDef(&def)
-		} else {
-			def := createBallFixureDef(g.newBody.Radius, g.newBody.Density, g.newBody.Friction, true)
-			g.newBody.Body.CreateFixtureFromDef(&def)
-		}
+		rebuildFixture(g.newBody)
 	}
 }
 
@@ -406,7 +549,7 @@ func handleEditSwapShape(g *Game) {
 	}
 
 	if g.newBody != nil {
-		mousePos := g.Window.MousePosition()
+		mousePos := g.input.MousePosition()
 		worldPos := screenToWorld(mousePos, g.camera)
 		g.World.DestroyBody(g.newBody.Body)
 
@@ -425,30 +568,33 @@ func handleEditSwapShape(g *Game) {
 }
 
 func handleEditMode(g *Game) {
-	// Save to file
-	if g.Window.JustPressed(pixelgl.KeyS) {
-		SaveToFile(g)
+	// Save as goes first, its binding includes the one of save
+	if g.justPressed(ActionEditSaveAs) {
+		g.editorSaveAs(nil)
+	} else if g.justPressed(ActionEditSave) {
+		g.editorSave(nil)
+	}
+	if g.justPressed(ActionEditOpen) {
+		g.editorOpen()
 	}
 }
 
 func handleCarControls(g *Game) {
 	// Car controls
-	if !g.Window.Pressed(pixelgl.KeyLeft) && !g.Window.Pressed(pixelgl.KeyLeft) {
-		g.car.Stop()
+	var controls Controls
+	if g.pressed(ActionReverse) {
+		controls |= ControlBackward
 	}
-	if g.Window.Pressed(pixelgl.KeyLeft) {
-		g.car.Backwards()
+	if g.pressed(ActionAccelerate) {
+		controls |= ControlForward
 	}
-
-	if g.Window.Pressed(pixelgl.KeyRight) {
-		g.car.Forward()
+	if g.pressed(ActionBrake) {
+		controls |= ControlBrake
 	}
-
-	if g.Window.Pressed(pixelgl.KeySpace) {
-		g.car.Break()
+	if g.justPressed(ActionResetCar) {
+		controls |= ControlReset
 	}
 
-	if g.Window.JustPressed(pixelgl.Key1) {
-		g.resetCar()
-	}
+	g.replay.Record(controls)
+	g.car.Apply(controls)
 }
@@ -0,0 +1,121 @@
+package game
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// newTestGame starts a game without a window in a copy of the config and
+// levels, with its profile in a directory of its own, so tests don't touch the
+// files of the repository or of the user
+func newTestGame(t *testing.T) *Game {
+	t.Helper()
+	testDir(t)
+	g := &Game{}
+	if err := g.Initialize(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// testDir changes into a temporary directory holding a copy of the config and
+// levels
+func testDir(t *testing.T) {
+	t.Helper()
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"config.json", "level1.json", "level2.json"} {
+		copyFile(t, filepath.Join(root, name), filepath.Join(dir, name))
+	}
+	if err := os.Symlink(filepath.Join(root, "resources"), filepath.Join(dir, "resources")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func copyFile(t *testing.T, from, to string) {
+	t.Helper()
+	src, err := os.Open(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run updates the game once for every frame
+func run(g *Game, frames ...InputFrame) {
+	in := NewScriptedInput(frames...)
+	g.SetInput(in)
+	for in.Advance() {
+		g.Update(nil)
+	}
+}
+
+// tap presses the buttons for a frame and lets go of them in the next
+func tap(buttons ...pixelgl.Button) []InputFrame {
+	return []InputFrame{Press(buttons...), {}}
+}
+
+// click presses the left mouse button at pos for a frame
+func click(pos pixel.Vec) []InputFrame {
+	return []InputFrame{
+		{Mouse: pos, Pressed: []pixelgl.Button{pixelgl.MouseButtonLeft}},
+		{Mouse: pos},
+	}
+}
+
+// startLevel loads a level for playing, or into the editor
+func startLevel(g *Game, info LevelInfo, edit bool) {
+	g.states.Pop()
+	g.states.Push(LoadingState{levelInfo: info, edit: edit})
+	run(g, InputFrame{})
+}
+
+// playLevel loads a level and skips its start screen
+func playLevel(g *Game, info LevelInfo) {
+	startLevel(g, info, false)
+	g.states.Pop()
+	g.states.Push(PlayState{})
+}
+
+func stateName(state interface{}) string {
+	return fmt.Sprintf("%T", state)
+}
+
+// centerOnScreen is where the middle of body is in the window
+func centerOnScreen(g *Game, body *GameBody) pixel.Vec {
+	pos := body.Body.GetPosition()
+	return g.camera.WorldToScreen(pixel.V(pos.X, pos.Y))
+}
+
+// emptySpace is a point on screen above the level, where no body is
+func emptySpace(g *Game) pixel.Vec {
+	return g.camera.WorldToScreen(pixel.V(g.car.spawn.X, g.car.spawn.Y+30))
+}
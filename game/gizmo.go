@@ -0,0 +1,195 @@
+package game
+
+import (
+	"math"
+
+	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+const (
+	gizmoHandleSize     = 6  // Half size of a handle in screen pixels
+	gizmoRotateDistance = 30 // Screen pixels between a body and its rotation handle
+	minBodyExtent       = 0.05
+)
+
+type gizmoKind int
+
+const (
+	gizmoMove gizmoKind = iota
+	gizmoRotate
+	gizmoResize
+	gizmoRadius
+)
+
+// gizmoHandle is something on screen that can be dragged to change the selection
+type gizmoHandle struct {
+	kind gizmoKind
+	side pixel.Vec // Corner or edge of a box handle, in half extents
+	pos  pixel.Vec // Screen position
+}
+
+// gizmoDrag is a drag of a handle or of the selection itself
+type gizmoDrag struct {
+	handle gizmoHandle
+	start  pixel.Vec // World position of the mouse when the drag started
+	change bodyChange
+}
+
+// gizmoHandles are the handles of the selection. Resize handles are only
+// shown for a single body, rotation handles only when no entity is selected.
+func (g *Game) gizmoHandles(bodies []*GameBody) []gizmoHandle {
+	if len(bodies) == 0 {
+		return nil
+	}
+	cam := g.camera
+	var handles []gizmoHandle
+
+	rotates := g.canRotate(bodies)
+	if len(bodies) > 1 && !rotates {
+		return nil
+	}
+	if len(bodies) > 1 {
+		bounds := unionBounds(bodies)
+		top := cam.WorldToScreen(pixel.V(bounds.Center().X, bounds.Max.Y))
+		handles = append(handles, gizmoHandle{kind: gizmoRotate, pos: top.Add(pixel.V(0, gizmoRotateDistance))})
+		return handles
+	}
+
+	body := bodies[0]
+	toScreen := func(local pixel.Vec) pixel.Vec {
+		world := body.Body.GetWorldPoint(box2d.B2Vec2{X: local.X, Y: local.Y})
+		return cam.WorldToScreen(pixel.V(world.X, world.Y))
+	}
+	extent := body.HalfH
+	switch body.Shape {
+	case Rectangle:
+		for _, side := range []pixel.Vec{
+			{X: -1, Y: -1}, {X: 1, Y: -1}, {X: 1, Y: 1}, {X: -1, Y: 1},
+			{X: 1, Y: 0}, {X: -1, Y: 0}, {X: 0, Y: 1}, {X: 0, Y: -1},
+		} {
+			pos := toScreen(pixel.V(side.X*body.HalfW, side.Y*body.HalfH))
+			handles = append(handles, gizmoHandle{kind: gizmoResize, side: side, pos: pos})
+		}
+	case Circle:
+		extent = body.Radius
+		handles = append(handles, gizmoHandle{kind: gizmoRadius, pos: toScreen(pixel.V(body.Radius, 0))})
+	}
+	if !rotates {
+		return handles
+	}
+
+	// The rotation handle sits above the body in its own up direction
+	edge := toScreen(pixel.V(0, extent))
+	up := edge.Sub(toScreen(pixel.ZV)).Unit()
+	if up.Len() == 0 {
+		up = pixel.V(0, 1)
+	}
+	handles = append(handles, gizmoHandle{kind: gizmoRotate, pos: edge.Add(up.Scaled(gizmoRotateDistance))})
+	return handles
+}
+
+// handleAt is the handle under a screen position
+func (g *Game) handleAt(bodies []*GameBody, pos pixel.Vec) (gizmoHandle, bool) {
+	for _, h := range g.gizmoHandles(bodies) {
+		if math.Abs(pos.X-h.pos.X) <= gizmoHandleSize+2 && math.Abs(pos.Y-h.pos.Y) <= gizmoHandleSize+2 {
+			return h, true
+		}
+	}
+	return gizmoHandle{}, false
+}
+
+func (g *Game) beginDrag(bodies []*GameBody, handle gizmoHandle) *gizmoDrag {
+	drag := &gizmoDrag{handle: handle, start: g.camera.ScreenToWorld(g.input.MousePosition())}
+	drag.change.Begin(g, bodies)
+	return drag
+}
+
+// update applies the drag for the current mouse position
+func (drag *gizmoDrag) update(g *Game, bodies []*GameBody) {
+	mouse := g.camera.ScreenToWorld(g.input.MousePosition())
+	snap := g.profile.Settings.Snap
+	before := drag.change.before
+	first := before[0]
+
+	switch drag.handle.kind {
+	case gizmoMove:
+		pos := bodies[0].Body.GetPosition()
+		target := pixel.V(first.X, first.Y).Add(mouse.Sub(drag.start))
+		moveBodies(bodies, g.snapMove(bodies, target.Sub(pixel.V(pos.X, pos.Y))))
+
+	case gizmoRotate:
+		center := pixel.ZV
+		for _, b := range before {
+			center = center.Add(pixel.V(b.X, b.Y))
+		}
+		center = center.Scaled(1 / float64(len(before)))
+		angle := first.Angle + mouse.Sub(center).Angle() - drag.start.Sub(center).Angle()
+		if snap.Angle && snap.AngleStep > 0 {
+			angle = snap.snapAngle(angle)
+		}
+		rotateBodies(bodies, angle-bodies[0].Body.GetAngle())
+
+	case gizmoResize:
+		resizeBox(bodies[0], first, drag.handle.side, mouse, snap)
+
+	case gizmoRadius:
+		r := mouse.Sub(pixel.V(first.X, first.Y)).Len()
+		if snap.Grid {
+			r = snap.snapValue(r*2) / 2
+		}
+		body := bodies[0]
+		body.Radius = math.Max(minBodyExtent, r)
+		rebuildFixture(body)
+	}
+}
+
+// resizeBox moves the dragged side of a box to the mouse, keeping the
+// opposite side where it was when the drag started
+func resizeBox(body *GameBody, start BodyJson, side, mouse pixel.Vec, snap SnapSettings) {
+	rot := pixel.IM.Rotated(pixel.ZV, start.Angle)
+	local := rot.Unproject(mouse.Sub(pixel.V(start.X, start.Y)))
+
+	extent := func(sideDir, mouseLocal, half float64) (float64, float64) {
+		if sideDir == 0 {
+			return half, 0
+		}
+		anchor := -sideDir * half
+		size := (mouseLocal - anchor) * sideDir
+		if snap.Grid {
+			size = snap.snapValue(size)
+		}
+		half = math.Max(minBodyExtent, size/2)
+		return half, anchor + sideDir*half
+	}
+	hx, cx := extent(side.X, local.X, start.Hx)
+	hy, cy := extent(side.Y, local.Y, start.Hy)
+
+	center := pixel.V(start.X, start.Y).Add(rot.Project(pixel.V(cx, cy)))
+	body.HalfW, body.HalfH = hx, hy
+	rebuildFixture(body)
+	body.Body.SetTransform(box2d.B2Vec2{X: center.X, Y: center.Y}, start.Angle)
+}
+
+// renderGizmos draws the handles of the selection
+func (g *Game) renderGizmos(bodies []*GameBody) {
+	imd := g.imDraw
+	imd.SetMatrix(pixel.IM)
+	size := pixel.V(gizmoHandleSize, gizmoHandleSize)
+	for _, h := range g.gizmoHandles(bodies) {
+		switch h.kind {
+		case gizmoRotate:
+			imd.Color = colornames.Darkorange
+			imd.Push(h.pos)
+			imd.Circle(gizmoHandleSize, 0)
+		default:
+			imd.Color = colornames.White
+			imd.Push(h.pos.Sub(size), h.pos.Add(size))
+			imd.Rectangle(0)
+			imd.Color = colornames.Darkorange
+			imd.Push(h.pos.Sub(size), h.pos.Add(size))
+			imd.Rectangle(2)
+		}
+	}
+}
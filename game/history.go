@@ -0,0 +1,457 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bytearena/box2d"
+)
+
+const (
+	editHistoryLimit = 100
+	editHistoryShown = 8 // Commands listed in the info panel
+)
+
+// EditCommand is one reversible change to the edited level. Do and Undo
+// return the bodies to select afterwards.
+type EditCommand interface {
+	Do(g *Game) []*GameBody
+	Undo(g *Game) []*GameBody
+	String() string
+}
+
+// EditHistory holds the commands done in the editor, oldest first, and the
+// ones undone since, so they can be redone
+type EditHistory struct {
+	done   []EditCommand
+	undone []EditCommand
+	saved  int // Length of done when the level was saved, -1 when that state is gone
+}
+
+// Execute does cmd and adds it to the history
+func (h *EditHistory) Execute(g *Game, cmd EditCommand) []*GameBody {
+	body := cmd.Do(g)
+	h.Record(cmd)
+	return body
+}
+
+// Record adds a command that was already applied, like a move done while a
+// key was held. Anything undone before can't be redone after that.
+func (h *EditHistory) Record(cmd EditCommand) {
+	// The saved state was undone and can't be redone anymore
+	if h.saved > len(h.done) {
+		h.saved = -1
+	}
+	h.done = append(h.done, cmd)
+	if drop := len(h.done) - editHistoryLimit; drop > 0 {
+		h.done = h.done[drop:]
+		h.saved -= drop
+		if h.saved < 0 {
+			h.saved = -1
+		}
+	}
+	h.undone = nil
+}
+
+// Undo reverts the last command. ok is false when there is nothing to undo.
+func (h *EditHistory) Undo(g *Game) (bodies []*GameBody, ok bool) {
+	if len(h.done) == 0 {
+		return nil, false
+	}
+	cmd := h.done[len(h.done)-1]
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, cmd)
+	return cmd.Undo(g), true
+}
+
+// Redo does the last undone command again
+func (h *EditHistory) Redo(g *Game) (bodies []*GameBody, ok bool) {
+	if len(h.undone) == 0 {
+		return nil, false
+	}
+	cmd := h.undone[len(h.undone)-1]
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, cmd)
+	return cmd.Do(g), true
+}
+
+func (h *EditHistory) Clear() {
+	h.done = nil
+	h.undone = nil
+	h.saved = 0
+}
+
+// MarkSaved remembers the current state as the one in the level file
+func (h *EditHistory) MarkSaved() {
+	h.saved = len(h.done)
+}
+
+// Dirty reports whether the level changed since it was loaded or saved
+func (h *EditHistory) Dirty() bool {
+	return h.saved != len(h.done)
+}
+
+// Lines describes the last n commands for the info panel, with the next one
+// to redo after them
+func (h *EditHistory) Lines(n int) []string {
+	var lines []string
+	first := len(h.done) - n
+	if first < 0 {
+		first = 0
+	}
+	for i := first; i < len(h.done); i++ {
+		lines = append(lines, h.done[i].String())
+	}
+	if len(h.undone) > 0 {
+		lines = append(lines, "(redo) "+h.undone[len(h.undone)-1].String())
+	}
+	return lines
+}
+
+// bodyRef finds a level body by its list and index, or the body of an
+// entity. Commands keep refs rather than pointers because undoing a delete
+// creates a new body. The history is linear, so a ref always points at the
+// same body when its command is undone or redone.
+type bodyRef struct {
+	Cargo  bool
+	Index  int
+	Entity levelEntity
+}
+
+func (g *Game) bodyList(cargo bool) *[]*GameBody {
+	if cargo {
+		return &g.CargoBodies
+	}
+	return &g.Bodies
+}
+
+func (g *Game) refOf(body *GameBody) (bodyRef, bool) {
+	if e := g.entityOf(body); e != entityNone {
+		return bodyRef{Entity: e}, true
+	}
+	for _, cargo := range []bool{false, true} {
+		for i, b := range *g.bodyList(cargo) {
+			if b == body {
+				return bodyRef{Cargo: cargo, Index: i}, true
+			}
+		}
+	}
+	return bodyRef{}, false
+}
+
+func (g *Game) bodyAt(ref bodyRef) *GameBody {
+	if ref.Entity != entityNone {
+		return g.entityBody(ref.Entity)
+	}
+	return (*g.bodyList(ref.Cargo))[ref.Index]
+}
+
+func (g *Game) insertBody(ref bodyRef, data BodyJson) *GameBody {
+	body := CreateBodies(g.World, []BodyJson{data})[0]
+	body.IsCargo = ref.Cargo
+	list := g.bodyList(ref.Cargo)
+	*list = append(*list, nil)
+	copy((*list)[ref.Index+1:], (*list)[ref.Index:])
+	(*list)[ref.Index] = body
+	return body
+}
+
+func (g *Game) removeBody(ref bodyRef) {
+	list := g.bodyList(ref.Cargo)
+	g.World.DestroyBody((*list)[ref.Index].Body)
+	*list = append((*list)[:ref.Index], (*list)[ref.Index+1:]...)
+}
+
+// applyBodyJson puts body back into the state data describes, rebuilding the
+// fixture when its size or material changed
+func applyBodyJson(body *GameBody, data BodyJson) {
+	body.Body.SetTransform(box2d.B2Vec2{X: data.X, Y: data.Y}, data.Angle)
+	body.Body.SetLinearVelocity(box2d.B2Vec2{})
+	body.Body.SetAngularVelocity(0)
+	body.Body.SetType(data.BodyType)
+	body.Texture = newBodyTexture(data.Texture, data.TextureMode, data.TextureU, data.TextureV)
+	body.IsSensor = data.Sensor
+	body.Body.GetFixtureList().SetSensor(data.Sensor)
+
+	if body.Shape == data.BodyShape && body.HalfW == data.Hx && body.HalfH == data.Hy && body.Radius == data.Radius &&
+		body.Density == data.Density && body.Friction == data.Friction && body.Restitution == data.Restitution {
+		return
+	}
+	body.Shape = data.BodyShape
+	body.HalfW, body.HalfH, body.Radius = data.Hx, data.Hy, data.Radius
+	body.Density, body.Friction, body.Restitution = data.Density, data.Friction, data.Restitution
+	rebuildFixture(body)
+}
+
+// rebuildFixture replaces the fixture of a box or ball with one matching its
+// current size and material
+func rebuildFixture(body *GameBody) {
+	old := body.Body.GetFixtureList()
+	sensor := old.IsSensor()
+	var def box2d.B2FixtureDef
+	switch body.Shape {
+	case Rectangle:
+		def = createBoxFixureDef(body.HalfW, body.HalfH, body.Density, body.Friction, sensor)
+	case Circle:
+		def = createBallFixureDef(body.Radius, body.Density, body.Friction, sensor)
+	default:
+		return
+	}
+	def.Restitution = body.Restitution
+	body.Body.DestroyFixture(old)
+	body.Body.CreateFixtureFromDef(&def)
+}
+
+// bodyKind names a body for the history
+func bodyKind(data BodyJson, ref bodyRef) string {
+	if ref.Entity != entityNone {
+		return entityNames[ref.Entity]
+	}
+	if ref.Cargo {
+		return "cargo"
+	}
+	if data.BodyShape == Circle {
+		return "ball"
+	}
+	return "box"
+}
+
+type createBodyCommand struct {
+	ref  bodyRef
+	body BodyJson
+}
+
+func (c *createBodyCommand) Do(g *Game) []*GameBody {
+	return []*GameBody{g.insertBody(c.ref, c.body)}
+}
+
+func (c *createBodyCommand) Undo(g *Game) []*GameBody {
+	g.removeBody(c.ref)
+	return nil
+}
+
+func (c *createBodyCommand) String() string {
+	return "Create " + bodyKind(c.body, c.ref)
+}
+
+type deleteBodyCommand struct {
+	ref  bodyRef
+	body BodyJson
+}
+
+func newDeleteBodyCommand(g *Game, ref bodyRef) *deleteBodyCommand {
+	return &deleteBodyCommand{ref: ref, body: newBodyJson(g.bodyAt(ref))}
+}
+
+func (c *deleteBodyCommand) Do(g *Game) []*GameBody {
+	g.removeBody(c.ref)
+	return nil
+}
+
+func (c *deleteBodyCommand) Undo(g *Game) []*GameBody {
+	return []*GameBody{g.insertBody(c.ref, c.body)}
+}
+
+func (c *deleteBodyCommand) String() string {
+	return "Delete " + bodyKind(c.body, c.ref)
+}
+
+// changeBodyCommand covers moving, rotating, resizing and property changes,
+// storing the whole body before and after
+type changeBodyCommand struct {
+	ref           bodyRef
+	before, after BodyJson
+}
+
+func (c *changeBodyCommand) Do(g *Game) []*GameBody {
+	body := g.bodyAt(c.ref)
+	applyBodyJson(body, c.after)
+	return []*GameBody{body}
+}
+
+func (c *changeBodyCommand) Undo(g *Game) []*GameBody {
+	body := g.bodyAt(c.ref)
+	applyBodyJson(body, c.before)
+	return []*GameBody{body}
+}
+
+// verb says what kind of change it was
+func (c *changeBodyCommand) verb() string {
+	b, a := c.before, c.after
+	switch {
+	case b.BodyShape != a.BodyShape:
+		return "Change shape of"
+	case b.Hx != a.Hx || b.Hy != a.Hy || b.Radius != a.Radius:
+		return "Resize"
+	case b.Density != a.Density || b.Friction != a.Friction || b.Restitution != a.Restitution ||
+		b.BodyType != a.BodyType || b.Sensor != a.Sensor:
+		return "Change properties of"
+	case b.Angle != a.Angle:
+		return "Rotate"
+	case b.X != a.X || b.Y != a.Y:
+		return "Move"
+	}
+	return "Change"
+}
+
+func (c *changeBodyCommand) String() string {
+	return fmt.Sprintf("%s %s", c.verb(), bodyKind(c.after, c.ref))
+}
+
+// groupCommand applies several commands as one, undoing them in reverse order
+type groupCommand struct {
+	verb     string
+	commands []EditCommand
+}
+
+func (c *groupCommand) Do(g *Game) []*GameBody {
+	var bodies []*GameBody
+	for _, cmd := range c.commands {
+		bodies = append(bodies, cmd.Do(g)...)
+	}
+	return bodies
+}
+
+func (c *groupCommand) Undo(g *Game) []*GameBody {
+	var bodies []*GameBody
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		bodies = append(bodies, c.commands[i].Undo(g)...)
+	}
+	return bodies
+}
+
+// String names the group after its bodies, leaving out the joints deleted
+// along with them
+func (c *groupCommand) String() string {
+	var bodies []EditCommand
+	for _, cmd := range c.commands {
+		if _, ok := cmd.(*deleteJointCommand); !ok {
+			bodies = append(bodies, cmd)
+		}
+	}
+	if len(bodies) == 1 {
+		return bodies[0].String()
+	}
+	return fmt.Sprintf("%s %d bodies", c.verb, len(bodies))
+}
+
+// newDeleteCommand deletes bodies, highest index first so the refs of the
+// others stay valid while deleting and while undoing. Entities are left alone.
+func newDeleteCommand(g *Game, bodies []*GameBody) EditCommand {
+	var refs []bodyRef
+	for _, body := range bodies {
+		if ref, ok := g.refOf(body); ok && ref.Entity == entityNone {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Cargo != refs[j].Cargo {
+			return refs[i].Cargo
+		}
+		return refs[i].Index > refs[j].Index
+	})
+	cmd := &groupCommand{verb: "Delete", commands: g.detachJoints(bodies)}
+	for _, ref := range refs {
+		cmd.commands = append(cmd.commands, newDeleteBodyCommand(g, ref))
+	}
+	return cmd
+}
+
+// bodyChange tracks an edit of the selection that takes several frames, like
+// holding an arrow key, so it ends up as a single command
+type bodyChange struct {
+	refs   []bodyRef
+	before []BodyJson
+	active bool
+}
+
+// Begin remembers the state of bodies when no change is going on yet
+func (c *bodyChange) Begin(g *Game, bodies []*GameBody) {
+	if c.active {
+		return
+	}
+	c.refs, c.before = nil, nil
+	for _, body := range bodies {
+		if ref, ok := g.refOf(body); ok {
+			c.refs = append(c.refs, ref)
+			c.before = append(c.before, newBodyJson(body))
+		}
+	}
+	c.active = true
+}
+
+// End records the bodies that changed since Begin
+func (c *bodyChange) End(g *Game) {
+	if !c.active {
+		return
+	}
+	c.active = false
+	cmd := &groupCommand{}
+	for i, ref := range c.refs {
+		after := newBodyJson(g.bodyAt(ref))
+		if after == c.before[i] {
+			continue
+		}
+		change := &changeBodyCommand{ref: ref, before: c.before[i], after: after}
+		if cmd.verb == "" {
+			cmd.verb = change.verb()
+		}
+		cmd.commands = append(cmd.commands, change)
+	}
+	if len(cmd.commands) > 0 {
+		g.editHistory.Record(cmd)
+	}
+}
+
+// selection is every selected level body and entity
+func (g *Game) selection() []*GameBody {
+	var selected []*GameBody
+	for _, cargo := range []bool{false, true} {
+		for _, b := range *g.bodyList(cargo) {
+			if b.IsSelected {
+				selected = append(selected, b)
+			}
+		}
+	}
+	for _, e := range levelEntities {
+		if b := g.entityBody(e); b.IsSelected {
+			selected = append(selected, b)
+		}
+	}
+	return selected
+}
+
+// selectBodies makes bodies the selection and switches the editor to match,
+// back to the main edit state when nothing is selected
+func (g *Game) selectBodies(bodies []*GameBody) {
+	for _, b := range g.selection() {
+		b.IsSelected = false
+	}
+	for _, j := range g.Joints {
+		j.IsSelected = false
+	}
+	g.editStates.Pop()
+	if len(bodies) == 0 {
+		g.editStates.Push(&MainEditState{})
+		return
+	}
+	for _, b := range bodies {
+		b.IsSelected = true
+	}
+	g.editStates.Push(&SelectedState{bodies: bodies})
+}
+
+// handleEditHistory undoes and redoes with Ctrl+Z and Ctrl+Y
+func handleEditHistory(g *Game) bool {
+	var bodies []*GameBody
+	var ok bool
+	if g.justPressed(ActionEditUndo) {
+		bodies, ok = g.editHistory.Undo(g)
+	} else if g.justPressed(ActionEditRedo) {
+		bodies, ok = g.editHistory.Redo(g)
+	}
+	if ok {
+		g.selectBodies(bodies)
+	}
+	return ok
+}
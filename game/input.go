@@ -2,19 +2,133 @@ package game
 
 import (
 	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
 )
 
+// InputSource is the keyboard and mouse as the game logic sees them. The
+// window is the real one, ScriptedInput stands in for it where there is no
+// window, like in tests.
+type InputSource interface {
+	Pressed(button pixelgl.Button) bool
+	JustPressed(button pixelgl.Button) bool
+	JustReleased(button pixelgl.Button) bool
+	Repeated(button pixelgl.Button) bool
+	MousePosition() pixel.Vec
+	MousePreviousPosition() pixel.Vec
+	MouseScroll() pixel.Vec
+	Typed() string
+}
+
+var _ InputSource = (*pixelgl.Window)(nil)
+
+// SetInput makes the game read its input from src instead of the window
+func (g *Game) SetInput(src InputSource) {
+	g.input = src
+}
+
+// screenBounds is the window area, or the default window size when running
+// without one
+func (g *Game) screenBounds() pixel.Rect {
+	if g.Window == nil {
+		return pixel.R(0, 0, ScreenWidth, ScreenHeight)
+	}
+	return g.Window.Bounds()
+}
+
+// InputFrame is what is held and typed during one frame. Repeated are the held
+// buttons the keyboard repeats this frame, as it does for a key held down.
+type InputFrame struct {
+	Pressed  []pixelgl.Button
+	Repeated []pixelgl.Button
+	Mouse    pixel.Vec
+	Scroll   pixel.Vec
+	Typed    string
+}
+
+// Press is a frame with the given buttons held and the mouse at the origin
+func Press(buttons ...pixelgl.Button) InputFrame {
+	return InputFrame{Pressed: buttons}
+}
+
+// Repeat is a frame with the given buttons held long enough to repeat
+func Repeat(buttons ...pixelgl.Button) InputFrame {
+	return InputFrame{Pressed: buttons, Repeated: buttons}
+}
+
+// ScriptedInput plays back a list of frames. Call Advance once per game update,
+// where the window would be updated. Before the first Advance and after the
+// last frame nothing is pressed.
+type ScriptedInput struct {
+	Frames   []InputFrame
+	frame    int
+	current  InputFrame
+	previous InputFrame
+}
+
+func NewScriptedInput(frames ...InputFrame) *ScriptedInput {
+	return &ScriptedInput{Frames: frames, frame: -1}
+}
+
+// Advance moves to the next frame and reports whether there was one
+func (s *ScriptedInput) Advance() bool {
+	s.previous = s.current
+	s.frame++
+	if s.frame >= len(s.Frames) {
+		s.current = InputFrame{Mouse: s.previous.Mouse}
+		return false
+	}
+	s.current = s.Frames[s.frame]
+	return true
+}
+
+func (s *ScriptedInput) Pressed(button pixelgl.Button) bool {
+	return containsButton(s.current.Pressed, button)
+}
+
+func (s *ScriptedInput) JustPressed(button pixelgl.Button) bool {
+	return containsButton(s.current.Pressed, button) && !containsButton(s.previous.Pressed, button)
+}
+
+func (s *ScriptedInput) JustReleased(button pixelgl.Button) bool {
+	return !containsButton(s.current.Pressed, button) && containsButton(s.previous.Pressed, button)
+}
+
+func (s *ScriptedInput) Repeated(button pixelgl.Button) bool {
+	return containsButton(s.current.Repeated, button) && containsButton(s.current.Pressed, button)
+}
+
+func (s *ScriptedInput) MousePosition() pixel.Vec {
+	return s.current.Mouse
+}
+
+func (s *ScriptedInput) MousePreviousPosition() pixel.Vec {
+	return s.previous.Mouse
+}
+
+func (s *ScriptedInput) MouseScroll() pixel.Vec {
+	return s.current.Scroll
+}
+
+func (s *ScriptedInput) Typed() string {
+	return s.current.Typed
+}
+
+func containsButton(buttons []pixelgl.Button, button pixelgl.Button) bool {
+	for _, b := range buttons {
+		if b == button {
+			return true
+		}
+	}
+	return false
+}
+
 func handleForce(g *Game) {
 	// Force applying
-	if g.Window.JustPressed(pixelgl.MouseButton1) && !g.EditMode {
-		pos := g.Window.MousePosition()
+	if g.justPressed(ActionPush) && !g.EditMode {
+		pos := g.input.MousePosition()
 
-		var bodies []*GameBody
-		bodies = append(bodies, g.car.body)
-		bodies = append(bodies, g.car.wheel1)
-		bodies = append(bodies, g.car.wheel2)
-		bodies = append(bodies, g.Bodies...)
+		bodies := forceTargets(g.car, g.Bodies)
 
 		for i := 0; i < len(bodies); i++ {
 			body := bodies[i].Body
@@ -23,21 +137,22 @@ func handleForce(g *Game) {
 			if collided {
 				g.isDragging = true
 				localPos := body.GetLocalPoint(worldPos)
-				g.forceDrag = &ForceDrag{body, &localPos}
+				g.forceDrag = &ForceDrag{body, &localPos, i}
 				break
 			}
 		}
 	}
 
 	// Force applying
-	if g.isDragging && g.Window.JustReleased(pixelgl.MouseButton1) {
+	if g.isDragging && g.justReleased(ActionPush) {
 		g.isDragging = false
 		worldPos := g.forceDrag.body.GetWorldPoint(*g.forceDrag.localPos)
 		mass := g.forceDrag.body.GetMass()
-		mousePos := g.Window.MousePosition()
+		mousePos := g.input.MousePosition()
 		mouseWorld := screenToWorld(mousePos, g.camera)
 		acc := box2d.B2Vec2Sub(mouseWorld, worldPos)
 		force := box2d.B2Vec2MulScalar(100*mass, acc)
 		g.forceDrag.body.ApplyForce(force, worldPos, true)
+		g.replay.RecordForce(g.forceDrag.target, *g.forceDrag.localPos, force)
 	}
 }
@@ -0,0 +1,40 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+func TestScriptedInput(t *testing.T) {
+	key := pixelgl.KeyA
+	in := NewScriptedInput(Press(key), Press(key), Repeat(key), InputFrame{})
+	want := []struct {
+		pressed, justPressed, justReleased, repeated bool
+	}{
+		{true, true, false, false},
+		{true, false, false, false},
+		{true, false, false, true},
+		{false, false, true, false},
+	}
+	for i, w := range want {
+		if !in.Advance() {
+			t.Fatalf("frame %d missing", i)
+		}
+		if got := in.Pressed(key); got != w.pressed {
+			t.Errorf("frame %d: Pressed %v, want %v", i, got, w.pressed)
+		}
+		if got := in.JustPressed(key); got != w.justPressed {
+			t.Errorf("frame %d: JustPressed %v, want %v", i, got, w.justPressed)
+		}
+		if got := in.JustReleased(key); got != w.justReleased {
+			t.Errorf("frame %d: JustReleased %v, want %v", i, got, w.justReleased)
+		}
+		if got := in.Repeated(key); got != w.repeated {
+			t.Errorf("frame %d: Repeated %v, want %v", i, got, w.repeated)
+		}
+	}
+	if in.Advance() {
+		t.Error("frames left after the script")
+	}
+}
@@ -0,0 +1,630 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Inspector lists the properties of the body being placed, of the selection
+// or of the selected joint in the info panel. A property is focused by clicking it or with
+// Tab, and changed in steps or by typing a value.
+type Inspector struct {
+	focus     string // Name of the focused property
+	editing   bool   // A value is being typed
+	input     string
+	bodies    []*GameBody // What the properties are shown for
+	joint     *GameJoint  // Shown instead of bodies when set
+	firstLine int         // Line of the info panel with the first property
+}
+
+// inspectorField is one property of a body, or of a joint when it has
+// getJoint. Choices are stored as their index.
+type inspectorField struct {
+	name    string
+	step    float64
+	choices []string
+	single  bool // Only for a single body, like the position
+	placed  bool // Only for bodies in the level, not the one following the mouse
+	sized   bool // Only for bodies of shape
+	shape   Shape
+	get     func(g *Game, b *GameBody) float64
+	set     func(g *Game, b *GameBody, v float64)
+	// command replaces set for bodies in the level when the change can't be
+	// recorded as a change of the bodies
+	command func(g *Game, bodies []*GameBody, v float64) EditCommand
+	// Joints are created again to change them, so their fields work on
+	// the data of the joint
+	getJoint func(j *JointJson) float64
+	setJoint func(j *JointJson, v float64)
+}
+
+var (
+	bodyTypeNames = []string{"Static", "Kinematic", "Dynamic"}
+	shapeNames    = []string{"Box", "Ball"}
+	yesNo         = []string{"No", "Yes"}
+)
+
+// Fields that entities share with bodies
+var (
+	xField = inspectorField{
+		name: "X", step: 0.1, single: true, placed: true,
+		get: func(g *Game, b *GameBody) float64 { return b.Body.GetPosition().X },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.Body.SetTransform(box2d.B2Vec2{X: v, Y: b.Body.GetPosition().Y}, b.Body.GetAngle())
+		},
+	}
+	yField = inspectorField{
+		name: "Y", step: 0.1, single: true, placed: true,
+		get: func(g *Game, b *GameBody) float64 { return b.Body.GetPosition().Y },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.Body.SetTransform(box2d.B2Vec2{X: b.Body.GetPosition().X, Y: v}, b.Body.GetAngle())
+		},
+	}
+	widthField = inspectorField{
+		name: "Width", step: 0.1, sized: true, shape: Rectangle,
+		get: func(g *Game, b *GameBody) float64 { return b.HalfW * 2 },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.HalfW = math.Max(minBodyExtent, v/2)
+			rebuildFixture(b)
+		},
+	}
+	heightField = inspectorField{
+		name: "Height", step: 0.1, sized: true, shape: Rectangle,
+		get: func(g *Game, b *GameBody) float64 { return b.HalfH * 2 },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.HalfH = math.Max(minBodyExtent, v/2)
+			rebuildFixture(b)
+		},
+	}
+	frictionField = inspectorField{
+		name: "Friction", step: 0.1,
+		get: func(g *Game, b *GameBody) float64 { return b.Friction },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.Friction = math.Max(0, v)
+			rebuildFixture(b)
+		},
+	}
+)
+
+var bodyFields = []inspectorField{
+	{
+		name: "Type", choices: bodyTypeNames,
+		get: func(g *Game, b *GameBody) float64 { return float64(b.Body.GetType()) },
+		set: func(g *Game, b *GameBody, v float64) { b.Body.SetType(uint8(v)) },
+	},
+	{
+		name: "Cargo", choices: yesNo,
+		get:     func(g *Game, b *GameBody) float64 { return boolValue(b.IsCargo) },
+		set:     func(g *Game, b *GameBody, v float64) { b.IsCargo = v == 1 },
+		command: func(g *Game, bodies []*GameBody, v float64) EditCommand { return newCargoCommand(g, bodies, v == 1) },
+	},
+	{
+		name: "Shape", choices: shapeNames,
+		get: func(g *Game, b *GameBody) float64 { return float64(b.Shape) },
+		set: setShape,
+	},
+	xField,
+	yField,
+	{
+		name: "Angle", step: 5, single: true,
+		get: func(g *Game, b *GameBody) float64 { return b.Body.GetAngle() * 180 / math.Pi },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.Body.SetTransform(b.Body.GetPosition(), v*math.Pi/180)
+		},
+	},
+	widthField,
+	heightField,
+	{
+		name: "Radius", step: 0.1, sized: true, shape: Circle,
+		get: func(g *Game, b *GameBody) float64 { return b.Radius },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.Radius = math.Max(minBodyExtent, v)
+			rebuildFixture(b)
+		},
+	},
+	{
+		name: "Density", step: 0.1,
+		get: func(g *Game, b *GameBody) float64 { return b.Density },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.Density = math.Max(0, v)
+			rebuildFixture(b)
+		},
+	},
+	frictionField,
+	{
+		name: "Restitution", step: 0.1,
+		get: func(g *Game, b *GameBody) float64 { return b.Restitution },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.Restitution = math.Max(0, v)
+			rebuildFixture(b)
+		},
+	},
+	{
+		name: "Sensor", choices: yesNo,
+		get: func(g *Game, b *GameBody) float64 { return boolValue(b.IsSensor) },
+		set: func(g *Game, b *GameBody, v float64) {
+			b.IsSensor = v == 1
+			// The body being placed stays a sensor until it is placed
+			if b != g.newBody {
+				b.Body.GetFixtureList().SetSensor(b.IsSensor)
+			}
+		},
+	},
+}
+
+// entityFields are the properties of each entity. The ground and the goal
+// stay level, so they have no angle.
+var entityFields = map[levelEntity][]inspectorField{
+	entityGround: {xField, yField, renamed(widthField, "Length"), heightField, frictionField},
+	entityGoal:   {xField, yField, widthField, heightField},
+	entitySpawn: {xField, yField, {
+		name: "Facing", choices: []string{"Right", "Left"},
+		get: func(g *Game, b *GameBody) float64 { return boolValue(g.car.facing < 0) },
+		command: func(g *Game, bodies []*GameBody, v float64) EditCommand {
+			if (v == 1) == (g.car.facing < 0) {
+				return nil
+			}
+			return &turnCarCommand{}
+		},
+	}},
+}
+
+// Fields that every type of joint shares
+var (
+	limitField = inspectorField{
+		name: "Limit", choices: yesNo,
+		getJoint: func(j *JointJson) float64 { return boolValue(j.Limit) },
+		setJoint: func(j *JointJson, v float64) { j.Limit = v == 1 },
+	}
+	motorField = inspectorField{
+		name: "Motor", choices: yesNo,
+		getJoint: func(j *JointJson) float64 { return boolValue(j.Motor) },
+		setJoint: func(j *JointJson, v float64) { j.Motor = v == 1 },
+	}
+	breakForceField = inspectorField{
+		name: "Break force", step: 10,
+		getJoint: func(j *JointJson) float64 { return j.BreakForce },
+		setJoint: func(j *JointJson, v float64) { j.BreakForce = math.Max(0, v) },
+	}
+	lengthField = inspectorField{
+		name: "Length", step: 0.1,
+		getJoint: func(j *JointJson) float64 { return j.Length },
+		setJoint: func(j *JointJson, v float64) { j.Length = math.Max(minBodyExtent, v) },
+	}
+)
+
+// jointFields are the properties of each type of joint. A break force of 0
+// never breaks. Angles and speeds of revolute joints are in degrees.
+var jointFields = map[JointType][]inspectorField{
+	JointRevolute: {
+		limitField,
+		{
+			name: "Lower angle", step: 5,
+			getJoint: func(j *JointJson) float64 { return j.Lower * 180 / math.Pi },
+			setJoint: func(j *JointJson, v float64) { j.Lower = math.Min(v*math.Pi/180, j.Upper) },
+		},
+		{
+			name: "Upper angle", step: 5,
+			getJoint: func(j *JointJson) float64 { return j.Upper * 180 / math.Pi },
+			setJoint: func(j *JointJson, v float64) { j.Upper = math.Max(v*math.Pi/180, j.Lower) },
+		},
+		motorField,
+		{
+			name: "Motor speed", step: 15,
+			getJoint: func(j *JointJson) float64 { return j.MotorSpeed * 180 / math.Pi },
+			setJoint: func(j *JointJson, v float64) { j.MotorSpeed = v * math.Pi / 180 },
+		},
+		{
+			name: "Max torque", step: 10,
+			getJoint: func(j *JointJson) float64 { return j.MaxMotor },
+			setJoint: func(j *JointJson, v float64) { j.MaxMotor = math.Max(0, v) },
+		},
+		breakForceField,
+	},
+	JointPrismatic: {
+		limitField,
+		{
+			name: "Lower", step: 0.1,
+			getJoint: func(j *JointJson) float64 { return j.Lower },
+			setJoint: func(j *JointJson, v float64) { j.Lower = math.Min(v, j.Upper) },
+		},
+		{
+			name: "Upper", step: 0.1,
+			getJoint: func(j *JointJson) float64 { return j.Upper },
+			setJoint: func(j *JointJson, v float64) { j.Upper = math.Max(v, j.Lower) },
+		},
+		motorField,
+		{
+			name: "Motor speed", step: 0.5,
+			getJoint: func(j *JointJson) float64 { return j.MotorSpeed },
+			setJoint: func(j *JointJson, v float64) { j.MotorSpeed = v },
+		},
+		{
+			name: "Max force", step: 10,
+			getJoint: func(j *JointJson) float64 { return j.MaxMotor },
+			setJoint: func(j *JointJson, v float64) { j.MaxMotor = math.Max(0, v) },
+		},
+		breakForceField,
+	},
+	JointDistance: {lengthField, breakForceField},
+	JointRope:     {lengthField, breakForceField},
+	JointWeld:     {breakForceField},
+}
+
+func renamed(f inspectorField, name string) inspectorField {
+	f.name = name
+	return f
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// setShape turns a box into a ball or back, sized to cover about the same area
+func setShape(g *Game, b *GameBody, v float64) {
+	shape := Shape(v)
+	if shape == b.Shape {
+		return
+	}
+	if shape == Circle && b.Radius <= 0 {
+		b.Radius = math.Max(b.HalfW, b.HalfH)
+	}
+	if shape == Rectangle && (b.HalfW <= 0 || b.HalfH <= 0) {
+		b.HalfW, b.HalfH = b.Radius, b.Radius
+	}
+	b.Shape = shape
+	rebuildFixture(b)
+	if b == g.newBody {
+		g.placeMode = BoxMode
+		if shape == Circle {
+			g.placeMode = BallMode
+		}
+	}
+}
+
+// cargoCommand moves bodies between the body and cargo lists. They are
+// deleted from one and created at the end of the other.
+type cargoCommand struct {
+	groupCommand
+	cargo bool
+	count int
+}
+
+func newCargoCommand(g *Game, bodies []*GameBody, cargo bool) EditCommand {
+	var moved []*GameBody
+	for _, b := range bodies {
+		if b.IsCargo != cargo {
+			moved = append(moved, b)
+		}
+	}
+	if len(moved) == 0 {
+		return nil
+	}
+	cmd := &cargoCommand{cargo: cargo, count: len(moved)}
+	cmd.commands = newDeleteCommand(g, moved).(*groupCommand).commands
+	first := len(*g.bodyList(cargo))
+	for i, b := range moved {
+		ref := bodyRef{Cargo: cargo, Index: first + i}
+		cmd.commands = append(cmd.commands, &createBodyCommand{ref: ref, body: newBodyJson(b)})
+	}
+
+	// The joints deleted with the bodies are created again where the
+	// bodies end up, lowest index first to put them back in order
+	movedRef := func(body *GameBody, end JointEndJson) JointEndJson {
+		if body == nil {
+			return end
+		}
+		ref, _ := g.refOf(body)
+		for i, b := range moved {
+			other, _ := g.refOf(b)
+			if b == body {
+				ref = bodyRef{Cargo: cargo, Index: first + i}
+				break
+			} else if other.Cargo == ref.Cargo && other.Index < ref.Index {
+				ref.Index--
+			}
+		}
+		end.Index, end.Cargo = ref.Index, ref.Cargo
+		return end
+	}
+	for i := len(cmd.commands) - 1; i >= 0; i-- {
+		if del, ok := cmd.commands[i].(*deleteJointCommand); ok {
+			j := g.Joints[del.index]
+			data := j.Data
+			data.BodyA, data.BodyB = movedRef(j.A, data.BodyA), movedRef(j.B, data.BodyB)
+			cmd.commands = append(cmd.commands, &createJointCommand{index: del.index, joint: data})
+		}
+	}
+	return cmd
+}
+
+func (c *cargoCommand) String() string {
+	what := fmt.Sprintf("%d bodies", c.count)
+	for _, cmd := range c.commands {
+		if del, ok := cmd.(*deleteBodyCommand); ok && c.count == 1 {
+			what = bodyKind(del.body, bodyRef{})
+			break
+		}
+	}
+	if c.cargo {
+		return fmt.Sprintf("Make %s cargo", what)
+	}
+	return fmt.Sprintf("Make %s not cargo", what)
+}
+
+// fields are the properties shown for the bodies or the joint
+func (in *Inspector) fields(g *Game) []inspectorField {
+	if in.joint != nil {
+		return jointFields[in.joint.Data.Type]
+	}
+	if len(in.bodies) == 0 {
+		return nil
+	}
+	sameShape := true
+	for _, b := range in.bodies {
+		sameShape = sameShape && b.Shape == in.bodies[0].Shape
+	}
+	placing := in.bodies[0] == g.newBody
+	if !placing {
+		// Entities have properties of their own, so they aren't edited
+		// together with other bodies
+		for _, b := range in.bodies {
+			if e := g.entityOf(b); e != entityNone && len(in.bodies) == 1 {
+				return entityFields[e]
+			} else if e != entityNone {
+				return nil
+			}
+		}
+	}
+	var fields []inspectorField
+	for _, f := range bodyFields {
+		if f.single && len(in.bodies) > 1 || f.placed && placing || f.sized && (!sameShape || f.shape != in.bodies[0].Shape) {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// inspected are the bodies the inspector shows, the one being placed or the
+// selection
+func (g *Game) inspected() []*GameBody {
+	if g.newBody != nil {
+		return []*GameBody{g.newBody}
+	}
+	return g.selection()
+}
+
+// show switches to other bodies or another joint, giving up a value being
+// typed
+func (in *Inspector) show(bodies []*GameBody, joint *GameJoint) {
+	if !sameBodies(in.bodies, bodies) || in.joint != joint {
+		in.bodies, in.joint = bodies, joint
+		in.editing = false
+	}
+}
+
+func fieldIndex(fields []inspectorField, name string) int {
+	for i, f := range fields {
+		if f.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Update handles the input for the inspector of bodies or a joint. It returns
+// true when it used the input, which the rest of the editor should then ignore.
+func (in *Inspector) Update(g *Game, bodies []*GameBody, joint *GameJoint) bool {
+	in.show(bodies, joint)
+	fields := in.fields(g)
+	if len(fields) == 0 {
+		return false
+	}
+
+	// Clicking a property focuses it, clicking anywhere else stops typing
+	if g.justPressed(ActionEditSelect) {
+		line := g.infoText.LineAt(g.screenBounds(), g.input.MousePosition()) - in.firstLine
+		if line >= 0 && line < len(fields) {
+			in.focus, in.editing = fields[line].name, false
+			return true
+		}
+		in.editing = false
+	}
+
+	i := fieldIndex(fields, in.focus)
+	if in.editing && i >= 0 {
+		in.updateTyping(g, fields[i])
+		return true
+	}
+	in.editing = false
+
+	// Shift+Tab also matches Tab, so it goes first
+	switch {
+	case g.justPressed(ActionEditPrevField):
+		i--
+		if i < 0 {
+			i = len(fields) - 1
+		}
+	case g.justPressed(ActionEditNextField):
+		i = (i + 1) % len(fields)
+	}
+	if i < 0 {
+		return false
+	}
+	f := fields[i]
+	in.focus = f.name
+
+	dir := 0.0
+	if g.repeated(ActionEditDecrease) {
+		dir = -1
+	} else if g.repeated(ActionEditIncrease) {
+		dir = 1
+	}
+	if f.choices != nil && g.justPressed(ActionConfirm) {
+		dir = 1
+	}
+	if dir != 0 {
+		in.apply(g, f, func(b *GameBody) float64 { return f.stepped(in.get(g, f, b), dir) })
+		return true
+	}
+
+	// Typing a digit or pressing Enter starts entering a value. A '.' or
+	// '-' alone doesn't, those are keys of the editor.
+	if typed := numberInput(g.input.Typed()); f.choices == nil && (strings.ContainsAny(typed, "0123456789") || g.justPressed(ActionConfirm)) {
+		in.editing, in.input = true, typed
+		return true
+	}
+	return false
+}
+
+func (in *Inspector) updateTyping(g *Game, f inspectorField) {
+	in.input += numberInput(g.input.Typed())
+	if g.input.JustPressed(pixelgl.KeyBackspace) || g.input.Repeated(pixelgl.KeyBackspace) {
+		if len(in.input) > 0 {
+			in.input = in.input[:len(in.input)-1]
+		}
+	}
+	if g.justPressed(ActionBack) {
+		in.editing = false
+	}
+	if g.justPressed(ActionConfirm) {
+		in.editing = false
+		v, err := strconv.ParseFloat(in.input, 64)
+		if err != nil {
+			fmt.Printf("Not a number: %q\n", in.input)
+			return
+		}
+		in.apply(g, f, func(*GameBody) float64 { return v })
+	}
+}
+
+// stepped is v moved one step in dir, going round the choices
+func (f inspectorField) stepped(v, dir float64) float64 {
+	if f.choices == nil {
+		return v + dir*f.step
+	}
+	n := len(f.choices)
+	return float64((int(v) + int(dir) + n) % n)
+}
+
+// get is the property of body, or of the joint when one is shown
+func (in *Inspector) get(g *Game, f inspectorField, body *GameBody) float64 {
+	if in.joint != nil {
+		return f.getJoint(&in.joint.Data)
+	}
+	return f.get(g, body)
+}
+
+// apply sets the property of every body, or of the joint, as one step in the
+// history
+func (in *Inspector) apply(g *Game, f inspectorField, value func(b *GameBody) float64) {
+	if in.joint != nil {
+		index := g.jointIndex(in.joint)
+		before := g.jointJson(in.joint)
+		after := before
+		f.setJoint(&after, value(nil))
+		if after != before {
+			g.editHistory.Execute(g, &changeJointCommand{index: index, before: before, after: after})
+			g.selectJoint(g.Joints[index])
+		}
+		return
+	}
+	if in.bodies[0] == g.newBody {
+		f.set(g, g.newBody, value(g.newBody))
+		return
+	}
+	if f.command != nil {
+		if cmd := f.command(g, in.bodies, value(in.bodies[0])); cmd != nil {
+			g.selectBodies(g.editHistory.Execute(g, cmd))
+		}
+		return
+	}
+	change := bodyChange{}
+	change.Begin(g, in.bodies)
+	for _, b := range in.bodies {
+		f.set(g, b, value(b))
+	}
+	change.End(g)
+}
+
+// Write lists the properties in the info panel, marking the focused one
+func (in *Inspector) Write(g *Game, l *Label) {
+	fields := in.fields(g)
+	if len(fields) == 0 {
+		return
+	}
+	if in.joint != nil {
+		fmt.Fprintf(l, "%s joint\n", in.joint.Data.Type)
+	} else if e := g.entityOf(in.bodies[0]); e != entityNone {
+		fmt.Fprintf(l, "Properties of the %s\n", entityNames[e])
+	} else if len(in.bodies) == 1 {
+		fmt.Fprintln(l, "Properties")
+	} else {
+		fmt.Fprintf(l, "%d bodies selected\n", len(in.bodies))
+	}
+	in.firstLine = len(l.lines())
+	for _, f := range fields {
+		mark := "  "
+		value := in.value(g, f)
+		if f.name == in.focus {
+			mark = "> "
+			if in.editing {
+				value = in.input + "_"
+			}
+		}
+		fmt.Fprintf(l, "%s%s: %s\n", mark, f.name, value)
+	}
+}
+
+// value shows the property, or that the bodies differ in it
+func (in *Inspector) value(g *Game, f inspectorField) string {
+	var v float64
+	if in.joint != nil {
+		v = f.getJoint(&in.joint.Data)
+	} else {
+		v = f.get(g, in.bodies[0])
+		for _, b := range in.bodies[1:] {
+			if f.get(g, b) != v {
+				return "mixed"
+			}
+		}
+	}
+	if f.choices != nil {
+		if i := int(v); i >= 0 && i < len(f.choices) {
+			return f.choices[i]
+		}
+		return "?"
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// numberInput keeps the characters of s that can be part of a number
+func numberInput(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func sameBodies(a, b []*GameBody) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Keys of the editor that are also part of a number don't start typing into
+// the inspector
+func TestInspectorTyping(t *testing.T) {
+	tests := []struct {
+		name    string
+		typed   InputFrame
+		editing bool
+	}{
+		{"digit", InputFrame{Typed: "3"}, true},
+		{"rotate", InputFrame{Pressed: []pixelgl.Button{pixelgl.KeyPeriod}, Typed: "."}, false},
+		{"enter", Press(pixelgl.KeyEnter), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			startLevel(g, g.config.Levels[0], true)
+			g.selectBodies([]*GameBody{g.Bodies[0]})
+			run(g, InputFrame{})
+			g.inspector.focus = "X"
+
+			run(g, tt.typed)
+			if g.inspector.editing != tt.editing {
+				t.Errorf("editing %v, want %v", g.inspector.editing, tt.editing)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package game
+
+import "github.com/bytearena/box2d"
+
+type JointType int
+
+const (
+	JointRevolute JointType = iota
+	JointPrismatic
+	JointDistance
+	JointRope
+	JointWeld
+)
+
+var jointTypeNames = []string{"Revolute", "Prismatic", "Distance", "Rope", "Weld"}
+
+func (t JointType) String() string {
+	if t >= 0 && int(t) < len(jointTypeNames) {
+		return jointTypeNames[t]
+	}
+	return "Unknown"
+}
+
+// GameJoint is a joint of the level. A and B are nil for the world. Joint is
+// nil once the joint broke.
+type GameJoint struct {
+	Joint      box2d.B2JointInterface
+	Data       JointJson // As the joint was created
+	A          *GameBody
+	B          *GameBody
+	IsSelected bool
+}
+
+// reactingJoint is implemented by every box2d joint type we create
+type reactingJoint interface {
+	GetReactionForce(invDt float64) box2d.B2Vec2
+}
+
+// newWorldAnchor is the body that joints hold on to the world by. It has no
+// fixtures and stays at the origin, so points local to it are world points.
+func newWorldAnchor(world *box2d.B2World) *box2d.B2Body {
+	def := box2d.MakeB2BodyDef()
+	return world.CreateBody(&def)
+}
+
+// CreateJoints creates the joints of a level between its bodies, leaving out
+// joints whose bodies are missing
+func CreateJoints(world *box2d.B2World, anchor *box2d.B2Body, bodies, cargo []*GameBody, joints []JointJson) []*GameJoint {
+	end := func(e JointEndJson) (*GameBody, bool) {
+		if e.World {
+			return nil, true
+		}
+		list := bodies
+		if e.Cargo {
+			list = cargo
+		}
+		if e.Index < 0 || e.Index >= len(list) {
+			return nil, false
+		}
+		return list[e.Index], true
+	}
+
+	var created []*GameJoint
+	for _, data := range joints {
+		a, okA := end(data.BodyA)
+		b, okB := end(data.BodyB)
+		if !okA || !okB || a == b {
+			continue
+		}
+		created = append(created, createJoint(world, anchor, a, b, data))
+	}
+	return created
+}
+
+// createJoint creates the joint data describes between a and b, where nil
+// stands for the world
+func createJoint(world *box2d.B2World, anchor *box2d.B2Body, a, b *GameBody, data JointJson) *GameJoint {
+	bodyA, bodyB := anchor, anchor
+	if a != nil {
+		bodyA = a.Body
+	}
+	if b != nil {
+		bodyB = b.Body
+	}
+	anchorA := box2d.B2Vec2{X: data.BodyA.X, Y: data.BodyA.Y}
+	anchorB := box2d.B2Vec2{X: data.BodyB.X, Y: data.BodyB.Y}
+
+	var def box2d.B2JointDefInterface
+	switch data.Type {
+	case JointRevolute:
+		d := box2d.MakeB2RevoluteJointDef()
+		d.LocalAnchorA, d.LocalAnchorB, d.ReferenceAngle = anchorA, anchorB, data.ReferenceAngle
+		d.EnableLimit, d.LowerAngle, d.UpperAngle = data.Limit, data.Lower, data.Upper
+		d.EnableMotor, d.MotorSpeed, d.MaxMotorTorque = data.Motor, data.MotorSpeed, data.MaxMotor
+		def = &d
+	case JointPrismatic:
+		d := box2d.MakeB2PrismaticJointDef()
+		d.LocalAnchorA, d.LocalAnchorB, d.ReferenceAngle = anchorA, anchorB, data.ReferenceAngle
+		if axis := (box2d.B2Vec2{X: data.AxisX, Y: data.AxisY}); axis.Length() > 0 {
+			axis.Normalize()
+			d.LocalAxisA = axis
+		}
+		d.EnableLimit, d.LowerTranslation, d.UpperTranslation = data.Limit, data.Lower, data.Upper
+		d.EnableMotor, d.MotorSpeed, d.MaxMotorForce = data.Motor, data.MotorSpeed, data.MaxMotor
+		def = &d
+	case JointDistance:
+		d := box2d.MakeB2DistanceJointDef()
+		d.LocalAnchorA, d.LocalAnchorB, d.Length = anchorA, anchorB, data.Length
+		def = &d
+	case JointRope:
+		d := box2d.MakeB2RopeJointDef()
+		d.LocalAnchorA, d.LocalAnchorB, d.MaxLength = anchorA, anchorB, data.Length
+		def = &d
+	default:
+		d := box2d.MakeB2WeldJointDef()
+		d.LocalAnchorA, d.LocalAnchorB, d.ReferenceAngle = anchorA, anchorB, data.ReferenceAngle
+		def = &d
+	}
+	def.SetBodyA(bodyA)
+	def.SetBodyB(bodyB)
+	return &GameJoint{Joint: world.CreateJoint(def), Data: data, A: a, B: b}
+}
+
+// breakJoints destroys the joints that were pulled harder than their break
+// force during the last step
+func breakJoints(world *box2d.B2World, joints []*GameJoint) {
+	for _, j := range joints {
+		if j.Joint == nil || j.Data.BreakForce <= 0 {
+			continue
+		}
+		force := j.Joint.(reactingJoint).GetReactionForce(1 / TimeStep)
+		if force.Length() > j.Data.BreakForce {
+			world.DestroyJoint(j.Joint)
+			j.Joint = nil
+		}
+	}
+}
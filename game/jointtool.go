@@ -0,0 +1,388 @@
+package game
+
+import (
+	"strings"
+
+	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel"
+	"golang.org/x/image/colornames"
+)
+
+const jointPickDistance = 8 // Screen pixels from a joint that still select it
+
+// JointToolState creates a joint from two clicks. The first click picks body A
+// and, for joints with a single anchor, where the anchor goes. The second
+// picks body B, or the world on empty space or an entity.
+type JointToolState struct {
+	kind  JointType
+	first *jointEnd
+}
+
+// JointSelectedState shows the selected joint in the inspector
+type JointSelectedState struct {
+	joint *GameJoint
+}
+
+// jointEnd is a clicked body, nil for the world, and the world point clicked
+type jointEnd struct {
+	body *GameBody
+	pos  pixel.Vec
+}
+
+// singleAnchor reports whether both bodies of the joint share one anchor
+func (t JointType) singleAnchor() bool {
+	return t == JointRevolute || t == JointPrismatic || t == JointWeld
+}
+
+func (g *Game) startJointTool() {
+	g.selectBodies(nil)
+	state := &JointToolState{}
+	g.editStates.Pop()
+	g.editStates.Push(state)
+	state.prompt(g)
+}
+
+func (state *JointToolState) prompt(g *Game) {
+	kind := strings.ToLower(state.kind.String())
+	if state.first == nil {
+		g.editorMessage("New %s joint, %s for another type: click the first body", kind, g.key(ActionEditNewJoint))
+	} else {
+		g.editorMessage("New %s joint: click the second body, or empty space for the world", kind)
+	}
+}
+
+func (state *JointToolState) Update(g *Game) {
+	if g.justPressed(ActionBack) {
+		if state.first != nil {
+			state.first = nil
+			state.prompt(g)
+			return
+		}
+		g.editMessage = ""
+		g.selectBodies(nil)
+		return
+	}
+	if g.justPressed(ActionEditNewJoint) {
+		state.kind = (state.kind + 1) % JointType(len(jointTypeNames))
+		state.prompt(g)
+	}
+	if !g.justPressed(ActionEditSelect) {
+		return
+	}
+
+	end := g.jointEndAt(g.input.MousePosition())
+	if state.first == nil {
+		state.first = &end
+		state.prompt(g)
+		return
+	}
+	if end.body == state.first.body {
+		g.editorMessage("A joint needs two bodies, or a body and the world")
+		return
+	}
+	cmd := &createJointCommand{index: len(g.Joints), joint: g.newJointJson(state.kind, *state.first, end)}
+	g.editHistory.Execute(g, cmd)
+	g.editMessage = ""
+	g.selectJoint(g.Joints[cmd.index])
+}
+
+func (state *JointToolState) Render(g *Game) {
+	if state.first == nil {
+		return
+	}
+	imd := g.imDraw
+	imd.SetMatrix(pixel.IM)
+	imd.Color = colornames.Darkorange
+	first := g.camera.WorldToScreen(state.first.pos)
+	imd.Push(first, g.input.MousePosition())
+	imd.Line(2)
+	imd.Push(first)
+	imd.Circle(4, 2)
+}
+
+// jointEndAt is the body under a screen position and the world point there,
+// snapped to the grid when grid snapping is on
+func (g *Game) jointEndAt(screen pixel.Vec) jointEnd {
+	pos := g.camera.ScreenToWorld(screen)
+	if snap := g.profile.Settings.Snap; snap.Grid {
+		pos = pixel.V(snap.snapValue(pos.X), snap.snapValue(pos.Y))
+	}
+	body := pickBody(g, screen)
+	if body != nil && g.entityOf(body) != entityNone {
+		body = nil
+	}
+	return jointEnd{body: body, pos: pos}
+}
+
+// newJointJson describes a joint of type kind between two clicked ends
+func (g *Game) newJointJson(kind JointType, a, b jointEnd) JointJson {
+	anchorB := b.pos
+	if kind.singleAnchor() {
+		anchorB = a.pos
+	}
+	data := JointJson{
+		Type:           kind,
+		BodyA:          g.jointEnd(a.body, localPoint(a.body, a.pos)),
+		BodyB:          g.jointEnd(b.body, localPoint(b.body, anchorB)),
+		ReferenceAngle: bodyAngle(b.body) - bodyAngle(a.body),
+	}
+	switch kind {
+	case JointPrismatic:
+		// Bodies slide along the line between the clicks
+		axis := b.pos.Sub(a.pos)
+		if axis.Len() < minBodyExtent {
+			axis = pixel.V(1, 0)
+		}
+		axis = axis.Unit()
+		if a.body != nil {
+			local := a.body.Body.GetLocalVector(box2d.B2Vec2{X: axis.X, Y: axis.Y})
+			axis = pixel.V(local.X, local.Y)
+		}
+		data.AxisX, data.AxisY = axis.X, axis.Y
+	case JointDistance, JointRope:
+		data.Length = b.pos.Sub(a.pos).Len()
+	}
+	return data
+}
+
+// localPoint is a world point local to body, or the world point itself for the world
+func localPoint(body *GameBody, pos pixel.Vec) JointEndJson {
+	if body == nil {
+		return JointEndJson{X: pos.X, Y: pos.Y}
+	}
+	local := body.Body.GetLocalPoint(box2d.B2Vec2{X: pos.X, Y: pos.Y})
+	return JointEndJson{X: local.X, Y: local.Y}
+}
+
+func bodyAngle(body *GameBody) float64 {
+	if body == nil {
+		return 0
+	}
+	return body.Body.GetAngle()
+}
+
+// jointEnd points end at body where it is in the level now, or at the world
+func (g *Game) jointEnd(body *GameBody, end JointEndJson) JointEndJson {
+	ref, ok := g.refOf(body)
+	end.Index, end.Cargo, end.World = ref.Index, ref.Cargo, !ok
+	return end
+}
+
+// jointBody is the body at an end of a joint, nil for the world
+func (g *Game) jointBody(end JointEndJson) *GameBody {
+	if end.World {
+		return nil
+	}
+	return g.bodyAt(bodyRef{Cargo: end.Cargo, Index: end.Index})
+}
+
+// jointJson is a joint as level files store it, with the refs of its bodies
+// as they are now
+func (g *Game) jointJson(j *GameJoint) JointJson {
+	data := j.Data
+	data.BodyA = g.jointEnd(j.A, data.BodyA)
+	data.BodyB = g.jointEnd(j.B, data.BodyB)
+	return data
+}
+
+func (g *Game) jointIndex(joint *GameJoint) int {
+	for i, j := range g.Joints {
+		if j == joint {
+			return i
+		}
+	}
+	return -1
+}
+
+func (g *Game) insertJoint(index int, data JointJson) *GameJoint {
+	joint := createJoint(g.World, g.worldAnchor, g.jointBody(data.BodyA), g.jointBody(data.BodyB), data)
+	g.Joints = append(g.Joints, nil)
+	copy(g.Joints[index+1:], g.Joints[index:])
+	g.Joints[index] = joint
+	return joint
+}
+
+func (g *Game) removeJoint(index int) {
+	if j := g.Joints[index]; j.Joint != nil {
+		g.World.DestroyJoint(j.Joint)
+	}
+	g.Joints = append(g.Joints[:index], g.Joints[index+1:]...)
+}
+
+// jointKind names a joint for the history
+func jointKind(data JointJson) string {
+	return strings.ToLower(data.Type.String()) + " joint"
+}
+
+type createJointCommand struct {
+	index int
+	joint JointJson
+}
+
+func (c *createJointCommand) Do(g *Game) []*GameBody {
+	g.insertJoint(c.index, c.joint)
+	return nil
+}
+
+func (c *createJointCommand) Undo(g *Game) []*GameBody {
+	g.removeJoint(c.index)
+	return nil
+}
+
+func (c *createJointCommand) String() string {
+	return "Create " + jointKind(c.joint)
+}
+
+type deleteJointCommand struct {
+	index int
+	joint JointJson
+}
+
+func newDeleteJointCommand(g *Game, index int) *deleteJointCommand {
+	return &deleteJointCommand{index: index, joint: g.jointJson(g.Joints[index])}
+}
+
+func (c *deleteJointCommand) Do(g *Game) []*GameBody {
+	g.removeJoint(c.index)
+	return nil
+}
+
+func (c *deleteJointCommand) Undo(g *Game) []*GameBody {
+	g.insertJoint(c.index, c.joint)
+	return nil
+}
+
+func (c *deleteJointCommand) String() string {
+	return "Delete " + jointKind(c.joint)
+}
+
+// changeJointCommand changes the properties of a joint by creating it again
+type changeJointCommand struct {
+	index         int
+	before, after JointJson
+}
+
+func (c *changeJointCommand) Do(g *Game) []*GameBody {
+	g.removeJoint(c.index)
+	g.insertJoint(c.index, c.after)
+	return nil
+}
+
+func (c *changeJointCommand) Undo(g *Game) []*GameBody {
+	g.removeJoint(c.index)
+	g.insertJoint(c.index, c.before)
+	return nil
+}
+
+func (c *changeJointCommand) String() string {
+	return "Change " + jointKind(c.after)
+}
+
+// detachJoints deletes the joints holding any of bodies, highest index first.
+// They have to go before the bodies, which box2d would take them away with.
+func (g *Game) detachJoints(bodies []*GameBody) []EditCommand {
+	var commands []EditCommand
+	for i := len(g.Joints) - 1; i >= 0; i-- {
+		j := g.Joints[i]
+		if j.A != nil && containsBody(bodies, j.A) || j.B != nil && containsBody(bodies, j.B) {
+			commands = append(commands, newDeleteJointCommand(g, i))
+		}
+	}
+	return commands
+}
+
+// selectJoint makes joint the selection, shown in the inspector
+func (g *Game) selectJoint(joint *GameJoint) {
+	g.selectBodies(nil)
+	joint.IsSelected = true
+	g.editStates.Pop()
+	g.editStates.Push(&JointSelectedState{joint: joint})
+}
+
+// inspectedJoint is the joint the inspector shows, if one is selected
+func (g *Game) inspectedJoint() *GameJoint {
+	if state, ok := g.editStates.Top().(*JointSelectedState); ok {
+		return state.joint
+	}
+	return nil
+}
+
+func (state *JointSelectedState) Update(g *Game) {
+	if handleEditHistory(g) || handleEditClipboard(g, nil) {
+		return
+	}
+	if g.justPressed(ActionEditDelete) {
+		g.editHistory.Execute(g, newDeleteJointCommand(g, g.jointIndex(state.joint)))
+		g.selectBodies(nil)
+		return
+	}
+	if g.justPressed(ActionBack) {
+		g.selectBodies(nil)
+		return
+	}
+	if g.justPressed(ActionEditNewJoint) {
+		g.startJointTool()
+		return
+	}
+	if g.justPressed(ActionEditSelect) {
+		handleEditSelect(g)
+	}
+}
+
+// jointAnchors are the screen positions of the anchors of a joint
+func (g *Game) jointAnchors(j *GameJoint) (pixel.Vec, pixel.Vec) {
+	joint := j.Joint.(anchoredJoint)
+	a, b := joint.GetAnchorA(), joint.GetAnchorB()
+	return g.camera.WorldToScreen(pixel.V(a.X, a.Y)), g.camera.WorldToScreen(pixel.V(b.X, b.Y))
+}
+
+// jointAt is the joint with an anchor, or the line between them, at a screen
+// position
+func (g *Game) jointAt(pos pixel.Vec) *GameJoint {
+	for i := len(g.Joints) - 1; i >= 0; i-- {
+		j := g.Joints[i]
+		if j.Joint == nil {
+			continue
+		}
+		a, b := g.jointAnchors(j)
+		if pixel.L(a, b).Closest(pos).Sub(pos).Len() <= jointPickDistance {
+			return j
+		}
+	}
+	return nil
+}
+
+// renderJoints draws every joint as its anchors, a line between them and
+// lines to the bodies they hold. Anchors in the world are squares.
+func (g *Game) renderJoints() {
+	imd := g.imDraw
+	imd.SetMatrix(pixel.IM)
+	for _, j := range g.Joints {
+		if j.Joint == nil {
+			continue
+		}
+		imd.Color = colornames.Teal
+		if j.IsSelected {
+			imd.Color = colornames.Darkorange
+		}
+		a, b := g.jointAnchors(j)
+		for _, end := range []struct {
+			body   *GameBody
+			anchor pixel.Vec
+		}{{j.A, a}, {j.B, b}} {
+			if end.body == nil {
+				size := pixel.V(4, 4)
+				imd.Push(end.anchor.Sub(size), end.anchor.Add(size))
+				imd.Rectangle(0)
+				continue
+			}
+			center := end.body.Body.GetPosition()
+			imd.Push(g.camera.WorldToScreen(pixel.V(center.X, center.Y)), end.anchor)
+			imd.Line(1)
+			imd.Push(end.anchor)
+			imd.Circle(4, 2)
+		}
+		imd.Push(a, b)
+		imd.Line(2)
+	}
+}
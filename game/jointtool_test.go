@@ -0,0 +1,84 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+func TestJointTool(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames func(g *Game) []InputFrame
+		want   string
+		joints int
+		kind   JointType
+	}{
+		{
+			name:   "start",
+			frames: func(g *Game) []InputFrame { return tap(pixelgl.KeyJ) },
+			want:   "*game.JointToolState",
+		},
+		{
+			name: "cancel",
+			frames: func(g *Game) []InputFrame {
+				return append(tap(pixelgl.KeyJ), tap(pixelgl.KeyEscape)...)
+			},
+			want: "*game.MainEditState",
+		},
+		{
+			name: "same body twice",
+			frames: func(g *Game) []InputFrame {
+				frames := append(tap(pixelgl.KeyJ), click(centerOnScreen(g, g.Bodies[0]))...)
+				return append(frames, click(centerOnScreen(g, g.Bodies[0]))...)
+			},
+			want: "*game.JointToolState",
+		},
+		{
+			name: "to the world",
+			frames: func(g *Game) []InputFrame {
+				frames := append(tap(pixelgl.KeyJ), click(centerOnScreen(g, g.Bodies[0]))...)
+				return append(frames, click(emptySpace(g))...)
+			},
+			want:   "*game.JointSelectedState",
+			joints: 1,
+			kind:   JointRevolute,
+		},
+		{
+			name: "other type",
+			frames: func(g *Game) []InputFrame {
+				frames := append(tap(pixelgl.KeyJ), tap(pixelgl.KeyJ)...)
+				frames = append(frames, click(centerOnScreen(g, g.Bodies[0]))...)
+				return append(frames, click(emptySpace(g))...)
+			},
+			want:   "*game.JointSelectedState",
+			joints: 1,
+			kind:   JointPrismatic,
+		},
+		{
+			name: "undo",
+			frames: func(g *Game) []InputFrame {
+				frames := append(tap(pixelgl.KeyJ), click(centerOnScreen(g, g.Bodies[0]))...)
+				frames = append(frames, click(emptySpace(g))...)
+				return append(frames, tap(pixelgl.KeyLeftControl, pixelgl.KeyZ)...)
+			},
+			want: "*game.MainEditState",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			startLevel(g, g.config.Levels[0], true)
+			run(g, tt.frames(g)...)
+			if got := stateName(g.editStates.Top()); got != tt.want {
+				t.Fatalf("edit state %s, want %s", got, tt.want)
+			}
+			if len(g.Joints) != tt.joints {
+				t.Fatalf("%d joints, want %d", len(g.Joints), tt.joints)
+			}
+			if tt.joints > 0 && g.Joints[0].Data.Type != tt.kind {
+				t.Errorf("%v joint, want %v", g.Joints[0].Data.Type, tt.kind)
+			}
+		})
+	}
+}
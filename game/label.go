@@ -0,0 +1,227 @@
+package game
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Anchor is the point of the screen a label is placed relative to
+type Anchor int
+
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTop
+	AnchorTopRight
+	AnchorLeft
+	AnchorCenter
+	AnchorRight
+	AnchorBottomLeft
+	AnchorBottom
+	AnchorBottomRight
+)
+
+type TextStyle struct {
+	Font   string
+	Size   float64
+	Color  color.Color
+	Align  Align
+	Shadow bool
+}
+
+var (
+	TitleStyle   = TextStyle{Font: FontBold, Size: 28, Color: colornames.Black, Shadow: true}
+	HeadingStyle = TextStyle{Font: FontBold, Size: 36, Color: colornames.Black, Align: AlignCenter, Shadow: true}
+	BodyStyle    = TextStyle{Font: FontRegular, Size: 15, Color: colornames.Black}
+	ScoreStyle   = TextStyle{Font: FontBold, Size: 30, Color: colornames.Black, Align: AlignCenter, Shadow: true}
+)
+
+var (
+	shadowColor  = color.RGBA{0, 0, 0, 90}
+	shadowOffset = pixel.V(2, -2)
+)
+
+// uiScale is how much the HUD is enlarged in a window of size screen. It goes
+// in quarter steps so dragging the window edge doesn't rebuild the font
+// atlases on every frame.
+func uiScale(screen pixel.Rect) float64 {
+	s := math.Round(screen.H()/ScreenHeight*4) / 4
+	return math.Max(0.75, math.Min(3, s))
+}
+
+// Label is a block of text anchored to a screen edge. It is written to like
+// a text.Text and lays itself out when drawn, wrapping lines at Width.
+// Sizes are given for the default window and scaled with uiScale.
+type Label struct {
+	Style  TextStyle
+	Anchor Anchor
+	Offset pixel.Vec // Distance from the anchor, pointing into the screen
+	Width  float64   // Wrap width in pixels, 0 never wraps
+
+	content strings.Builder
+	fonts   *FontRegistry
+	scale   float64
+	atlas   TextStyle // Font and size the text was built with
+	text    *text.Text
+	laidOut bool
+	bounds  pixel.Rect
+}
+
+func NewLabel(fonts *FontRegistry, style TextStyle, anchor Anchor, offset pixel.Vec) *Label {
+	l := &Label{Style: style, Anchor: anchor, Offset: offset, fonts: fonts}
+	l.setScale(1)
+	return l
+}
+
+// setScale rebuilds the text when the scale or the font of the style changed
+func (l *Label) setScale(scale float64) {
+	if l.text != nil && scale == l.scale && l.atlas.Font == l.Style.Font && l.atlas.Size == l.Style.Size {
+		return
+	}
+	l.scale = scale
+	l.atlas = l.Style
+	l.text = text.New(pixel.ZV, l.fonts.Atlas(l.Style.Font, l.Style.Size*scale))
+	// Glyphs are white and tinted with a color mask when drawn, so the
+	// same text can be used for the shadow
+	l.text.Color = colornames.White
+	l.laidOut = false
+}
+
+func (l *Label) Write(p []byte) (int, error) {
+	l.laidOut = false
+	return l.content.Write(p)
+}
+
+func (l *Label) Clear() {
+	l.content.Reset()
+	l.laidOut = false
+}
+
+func (l *Label) SetColor(c color.Color) {
+	l.Style.Color = c
+}
+
+func (l *Label) String() string {
+	return l.content.String()
+}
+
+// lines splits the content into lines, wrapping words that don't fit in Width
+func (l *Label) lines() []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.TrimRight(l.content.String(), "\n"), "\n") {
+		if l.Width <= 0 {
+			lines = append(lines, paragraph)
+			continue
+		}
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && l.text.BoundsOf(candidate).W() > l.Width*l.scale {
+				lines = append(lines, line)
+				line = word
+			} else {
+				line = candidate
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (l *Label) layout() {
+	lines := l.lines()
+	lineHeight := l.text.LineHeight
+
+	width := 0.0
+	for _, line := range lines {
+		width = math.Max(width, l.text.BoundsOf(line).W())
+	}
+
+	l.text.Clear()
+	for i, line := range lines {
+		x := 0.0
+		switch l.Style.Align {
+		case AlignCenter:
+			x = (width - l.text.BoundsOf(line).W()) / 2
+		case AlignRight:
+			x = width - l.text.BoundsOf(line).W()
+		}
+		l.text.Dot = pixel.V(x, -float64(i)*lineHeight-l.text.Atlas().Ascent())
+		l.text.WriteString(line)
+	}
+	l.bounds = pixel.R(0, -float64(len(lines))*lineHeight, width, 0)
+	l.laidOut = true
+}
+
+// Bounds is the screen area the label covers in a window of size screen
+func (l *Label) Bounds(screen pixel.Rect) pixel.Rect {
+	l.setScale(uiScale(screen))
+	if !l.laidOut {
+		l.layout()
+	}
+	return l.bounds.Moved(l.position(screen))
+}
+
+// LineAt is the line of the label under pos, counting wrapped lines, or -1
+func (l *Label) LineAt(screen pixel.Rect, pos pixel.Vec) int {
+	bounds := l.Bounds(screen)
+	if !bounds.Contains(pos) {
+		return -1
+	}
+	return int((bounds.Max.Y - pos.Y) / l.text.LineHeight)
+}
+
+// position is where the top left corner of the text block goes on screen
+func (l *Label) position(screen pixel.Rect) pixel.Vec {
+	w, h := l.bounds.W(), l.bounds.H()
+	offset := l.Offset.Scaled(l.scale)
+	var x, y float64
+
+	switch l.Anchor {
+	case AnchorTopLeft, AnchorLeft, AnchorBottomLeft:
+		x = screen.Min.X + offset.X
+	case AnchorTop, AnchorCenter, AnchorBottom:
+		x = screen.Center().X - w/2 + offset.X
+	default:
+		x = screen.Max.X - offset.X - w
+	}
+
+	switch l.Anchor {
+	case AnchorTopLeft, AnchorTop, AnchorTopRight:
+		y = screen.Max.Y - offset.Y
+	case AnchorLeft, AnchorCenter, AnchorRight:
+		y = screen.Center().Y + h/2 - offset.Y
+	default:
+		y = screen.Min.Y + offset.Y + h
+	}
+	return pixel.V(math.Round(x), math.Round(y))
+}
+
+func (l *Label) Draw(win *pixelgl.Window) {
+	screen := win.Bounds()
+	l.setScale(uiScale(screen))
+	if !l.laidOut {
+		l.layout()
+	}
+	m := pixel.IM.Moved(l.position(screen))
+	if l.Style.Shadow {
+		l.text.DrawColorMask(win, m.Moved(shadowOffset.Scaled(l.scale)), shadowColor)
+	}
+	l.text.DrawColorMask(win, m, l.Style.Color)
+}
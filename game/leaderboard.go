@@ -0,0 +1,174 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const LeaderboardSize = 10
+
+var ErrReplayMismatch = errors.New("replay does not reproduce the entry")
+
+// LeaderboardEntry is one finished run together with the replay that proves it
+type LeaderboardEntry struct {
+	Player string
+	Date   time.Time
+	Score  int
+	Time   float64 // Seconds of simulated time
+	Replay *Replay
+}
+
+// Leaderboard holds the best runs on one level, best first
+type Leaderboard struct {
+	Level   string
+	Entries []LeaderboardEntry
+}
+
+// better ranks higher scores first and faster runs among equal scores
+func (e *LeaderboardEntry) better(other *LeaderboardEntry) bool {
+	if e.Score != other.Score {
+		return e.Score > other.Score
+	}
+	return e.Time < other.Time
+}
+
+// Rank is the position entry would get, or -1 when it doesn't make the board
+func (b *Leaderboard) Rank(entry *LeaderboardEntry) int {
+	for i := 0; i < len(b.Entries); i++ {
+		if entry.better(&b.Entries[i]) {
+			return i
+		}
+	}
+	if len(b.Entries) < LeaderboardSize {
+		return len(b.Entries)
+	}
+	return -1
+}
+
+func (b *Leaderboard) insert(entry LeaderboardEntry) int {
+	rank := b.Rank(&entry)
+	if rank < 0 {
+		return -1
+	}
+	b.Entries = append(b.Entries, LeaderboardEntry{})
+	copy(b.Entries[rank+1:], b.Entries[rank:])
+	b.Entries[rank] = entry
+	if len(b.Entries) > LeaderboardSize {
+		b.Entries = b.Entries[:LeaderboardSize]
+	}
+	return rank
+}
+
+// Verify simulates the entry's replay on level and checks it ends the way the
+// entry claims
+func (e *LeaderboardEntry) Verify(level string, data *LevelData) error {
+	if e.Replay == nil || e.Replay.Vehicle == nil || e.Replay.Level != level {
+		return ErrReplayMismatch
+	}
+	result := e.Replay.Simulate(data)
+	if !result.ReachedGoal || result.Score != e.Score || result.Time != e.Time {
+		return ErrReplayMismatch
+	}
+	return nil
+}
+
+// LeaderboardStore keeps a json file per level in the user config directory.
+// Boards are verified once when first loaded and kept in memory after that.
+type LeaderboardStore struct {
+	dir    string
+	boards map[string]*Leaderboard
+}
+
+func NewLeaderboardStore() (*LeaderboardStore, error) {
+	dir, err := appConfigDir("leaderboards")
+	if err != nil {
+		return nil, err
+	}
+	return &LeaderboardStore{dir: dir, boards: map[string]*Leaderboard{}}, nil
+}
+
+// Load returns the board of a level. Entries whose replays don't hold up are
+// dropped, so editing the file by hand doesn't get anyone on the board.
+func (s *LeaderboardStore) Load(level string) (*Leaderboard, error) {
+	if board, ok := s.boards[level]; ok {
+		return board, nil
+	}
+
+	board := &Leaderboard{Level: level}
+	data, err := os.ReadFile(s.path(level))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, board); err != nil {
+			return nil, err
+		}
+		board.Level = level
+		s.verify(board)
+	}
+
+	s.boards[level] = board
+	return board, nil
+}
+
+func (s *LeaderboardStore) verify(board *Leaderboard) {
+	entries := board.Entries
+	board.Entries = nil
+	if len(entries) == 0 {
+		return
+	}
+
+	levelData, err := ReadLevel(board.Level)
+	if err != nil {
+		fmt.Println("Leaderboard level unavailable:", err)
+		return
+	}
+	for _, entry := range entries {
+		if err := entry.Verify(board.Level, levelData); err != nil {
+			fmt.Printf("Dropping leaderboard entry of %s: %v\n", entry.Player, err)
+			continue
+		}
+		board.insert(entry)
+	}
+}
+
+// Submit verifies entry against the level file and adds it to the board when
+// it is good enough. It returns the rank taken, or -1 when it didn't make it.
+func (s *LeaderboardStore) Submit(entry LeaderboardEntry) (int, error) {
+	if entry.Replay == nil {
+		return -1, ErrReplayMismatch
+	}
+	level := entry.Replay.Level
+	board, err := s.Load(level)
+	if err != nil {
+		return -1, err
+	}
+	if board.Rank(&entry) < 0 {
+		return -1, nil
+	}
+
+	levelData, err := ReadLevel(level)
+	if err != nil {
+		return -1, err
+	}
+	if err := entry.Verify(level, levelData); err != nil {
+		return -1, err
+	}
+
+	rank := board.insert(entry)
+	data, err := json.Marshal(board)
+	if err != nil {
+		return -1, err
+	}
+	return rank, writeFileAtomic(s.path(level), data)
+}
+
+func (s *LeaderboardStore) path(level string) string {
+	return filepath.Join(s.dir, safeFilename(strings.TrimSuffix(level, ".json"))+".json")
+}
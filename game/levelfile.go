@@ -0,0 +1,261 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultEditLevel is opened in the editor until another level is opened or
+// saved. Saving it asks for a file of its own, so nobody's level ends up in it.
+var defaultEditLevel = LevelInfo{Name: "New level", Filename: "newlevel.json"}
+
+// levelFilename checks a typed file name, adding .json when missing. Levels
+// are kept next to config.json.
+func levelFilename(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("no file name given")
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("%q is not a plain file name", name)
+	}
+	if !strings.EqualFold(filepath.Ext(name), ".json") {
+		name += ".json"
+	}
+	if strings.EqualFold(name, "config.json") {
+		return "", errors.New("config.json is not a level")
+	}
+	return name, nil
+}
+
+// levelFiles are the level files that can be opened in the editor
+func levelFiles() []LevelInfo {
+	names, _ := filepath.Glob("*.json")
+	var levels []LevelInfo
+	for _, name := range names {
+		if strings.EqualFold(name, "config.json") {
+			continue
+		}
+		data, err := ReadLevel(name)
+		if err != nil || data.Name == "" && len(data.Bodies) == 0 {
+			continue
+		}
+		levels = append(levels, LevelInfo{Name: data.Name, Filename: name})
+	}
+	return levels
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// editorStatus is shown at the top of the editor
+func (g *Game) editorStatus() string {
+	status := fmt.Sprintf("Edit mode: %s (%s)", g.editLevel.Name, g.editLevel.Filename)
+	if g.editorHistory().Dirty() {
+		status += " *"
+	}
+	if g.editMessage != "" {
+		status += "\n" + g.editMessage
+	}
+	return status
+}
+
+func (g *Game) editorMessage(format string, args ...interface{}) {
+	g.editMessage = fmt.Sprintf(format, args...)
+	fmt.Println(g.editMessage)
+}
+
+// editorSave saves the edited level to its file, asking for a file of its own
+// first for the default level, or when the editor holds a level loaded from
+// another file. then runs once the level is saved.
+func (g *Game) editorSave(then func(g *Game)) {
+	if g.editLevel.Filename == defaultEditLevel.Filename || g.levelInfo.Filename != g.editLevel.Filename {
+		g.editorSaveAs(then)
+		return
+	}
+	g.writeEditLevel(g.editLevel, then)
+}
+
+// editorSaveAs asks for a file and level name to save the edited level as
+func (g *Game) editorSaveAs(then func(g *Game)) {
+	g.states.Push(&PromptState{
+		Title: "Save level as",
+		Label: "File",
+		Text:  g.editLevel.Filename,
+		OnText: func(g *Game, text string) {
+			filename, err := levelFilename(text)
+			if err != nil {
+				g.editorMessage("Can't save: %v", err)
+				return
+			}
+			g.askLevelName(filename, then)
+		},
+	})
+}
+
+func (g *Game) askLevelName(filename string, then func(g *Game)) {
+	g.states.Push(&PromptState{
+		Title: "Save level as",
+		Label: "Level name",
+		Text:  g.editLevel.Name,
+		OnText: func(g *Game, name string) {
+			info := LevelInfo{Name: strings.TrimSpace(name), Filename: filename}
+			if info.Name == "" {
+				info.Name = strings.TrimSuffix(filename, filepath.Ext(filename))
+			}
+			g.confirmOverwrite(info, then)
+		},
+	})
+}
+
+// confirmOverwrite asks before saving over a file other than the one the
+// level was loaded from
+func (g *Game) confirmOverwrite(info LevelInfo, then func(g *Game)) {
+	if info.Filename == g.levelInfo.Filename || !fileExists(info.Filename) {
+		g.writeEditLevel(info, then)
+		return
+	}
+	g.states.Push(&PromptState{
+		Title:   fmt.Sprintf("%s already exists", info.Filename),
+		Choices: []string{"Overwrite it", "Cancel"},
+		OnChoice: func(g *Game, choice int) {
+			if choice == 0 {
+				g.writeEditLevel(info, then)
+			}
+		},
+	})
+}
+
+// writeEditLevel saves the edited level as info and keeps the level list in
+// config.json up to date, offering to add the level when it isn't listed
+func (g *Game) writeEditLevel(info LevelInfo, then func(g *Game)) {
+	if err := SaveToFile(g, info); err != nil {
+		g.editorMessage("Saving failed: %v", err)
+		return
+	}
+	g.editLevel = info
+	g.levelInfo = &info
+	g.editorHistory().MarkSaved()
+	g.editorMessage("Saved %s", info.Filename)
+
+	finish := func(g *Game) {
+		if then != nil {
+			then(g)
+		}
+	}
+	if i := g.config.LevelIndex(info.Filename); i >= 0 {
+		if g.config.Levels[i].Name != info.Name {
+			g.config.Levels[i].Name = info.Name
+			g.saveConfig()
+		}
+		finish(g)
+		return
+	}
+	g.states.Push(&PromptState{
+		Title:   fmt.Sprintf("Add %s to the level list?", info.Name),
+		Choices: []string{"Add to the level list", "Not now"},
+		OnChoice: func(g *Game, choice int) {
+			if choice == 0 {
+				g.config.Levels = append(g.config.Levels, info)
+				g.saveConfig()
+			}
+			finish(g)
+		},
+		OnCancel: finish,
+	})
+}
+
+func (g *Game) saveConfig() {
+	if err := SaveConfig(g.config); err != nil {
+		g.editorMessage("Saving config.json failed: %v", err)
+	}
+}
+
+// editorOpen lists the level files to open one in the editor
+func (g *Game) editorOpen() {
+	g.confirmLeave(func(g *Game) {
+		levels := levelFiles()
+		var choices []string
+		for _, level := range levels {
+			choices = append(choices, fmt.Sprintf("%s (%s)", level.Name, level.Filename))
+		}
+		g.states.Push(&PromptState{
+			Title:   "Open level",
+			Choices: append(choices, "Cancel"),
+			OnChoice: func(g *Game, choice int) {
+				if choice < len(levels) {
+					g.openEditLevel(levels[choice])
+				}
+			},
+		})
+	})
+}
+
+// openEditLevel loads a level into the editor, replacing the editor state
+func (g *Game) openEditLevel(info LevelInfo) {
+	g.editLevel = info
+	g.leaveEditor()
+	g.states.Pop()
+	g.states.Push(LoadingState{levelInfo: info, edit: true})
+}
+
+// confirmLeave runs then right away when the edited level has no unsaved
+// changes. Otherwise it asks whether to save them, discard them or stay.
+func (g *Game) confirmLeave(then func(g *Game)) {
+	if !g.editorHistory().Dirty() {
+		then(g)
+		return
+	}
+	g.states.Push(&PromptState{
+		Title:   fmt.Sprintf("Save changes to %s?", g.editLevel.Name),
+		Choices: []string{"Save", "Discard changes", "Cancel"},
+		OnChoice: func(g *Game, choice int) {
+			switch choice {
+			case 0:
+				g.editorSave(then)
+			case 1:
+				g.editorHistory().Clear()
+				then(g)
+			}
+		},
+	})
+}
+
+// leaveEditor drops what the editor was doing, before switching away from it
+func (g *Game) leaveEditor() {
+	for !g.editStates.isEmpty() {
+		g.editStates.Pop()
+	}
+	if g.newBody != nil {
+		g.World.DestroyBody(g.newBody.Body)
+		g.newBody = nil
+	}
+	for _, b := range g.selection() {
+		b.IsSelected = false
+	}
+	for _, j := range g.Joints {
+		j.IsSelected = false
+	}
+	g.editMessage = ""
+}
+
+// CanClose is asked before the window closes. With unsaved changes in the
+// editor it keeps the window open and asks about them first.
+func (g *Game) CanClose() bool {
+	if g.closing || !g.editorHistory().Dirty() {
+		return true
+	}
+	g.Window.SetClosed(false)
+	if _, ok := g.states.Top().(*PromptState); !ok {
+		g.confirmLeave(func(g *Game) {
+			g.closing = true
+			g.Window.SetClosed(true)
+		})
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package game
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// The editor opened from play saves to the played level, not to the one it
+// had open before
+func TestEditPlayedLevel(t *testing.T) {
+	g := newTestGame(t)
+	g.editLevel = g.config.Levels[0]
+	playLevel(g, g.config.Levels[1])
+
+	run(g, tap(pixelgl.KeyE)...)
+	if g.editLevel != *g.levelInfo {
+		t.Errorf("editor saves to %v, want the played %v", g.editLevel, *g.levelInfo)
+	}
+}
+
+// Saving in the editor opened from play writes the played level, not the one
+// the editor had open before
+func TestEditPlayedLevelSaves(t *testing.T) {
+	g := newTestGame(t)
+	startLevel(g, g.config.Levels[1], true)
+	g.leaveEditor()
+	playLevel(g, g.config.Levels[0])
+
+	other, err := os.ReadFile("level2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run(g, tap(pixelgl.KeyE)...)
+	g.selectBodies([]*GameBody{g.Bodies[0]})
+	run(g, tap(pixelgl.KeyDelete)...)
+	run(g, tap(pixelgl.KeyS)...)
+
+	data, err := ReadLevel("level1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Bodies) != len(g.Bodies) {
+		t.Errorf("level1.json has %d bodies, want the %d edited ones", len(data.Bodies), len(g.Bodies))
+	}
+	if after, _ := os.ReadFile("level2.json"); string(after) != string(other) {
+		t.Error("level2.json was saved over with the edited level")
+	}
+}
+
+// Saving the editor opened after driving saves the level as it was made, not
+// where the car and bodies went
+func TestEditAfterDrivingSaves(t *testing.T) {
+	g := newTestGame(t)
+	playLevel(g, g.config.Levels[0])
+	before, err := ReadLevel("level1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []InputFrame
+	for i := 0; i < 120; i++ {
+		frames = append(frames, Press(pixelgl.KeyRight))
+	}
+	run(g, frames...)
+	run(g, tap(pixelgl.KeyE)...)
+	run(g, tap(pixelgl.KeyS)...)
+
+	after, err := ReadLevel("level1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spawn, want := after.spawn(), before.spawn(); spawn != want {
+		t.Errorf("spawn saved as %+v, want %+v", spawn, want)
+	}
+	if len(after.Bodies) != len(before.Bodies) {
+		t.Fatalf("%d bodies saved, want %d", len(after.Bodies), len(before.Bodies))
+	}
+	for i, b := range before.Bodies {
+		a := after.Bodies[i]
+		if math.Abs(a.X-b.X) > 1e-9 || math.Abs(a.Y-b.Y) > 1e-9 || math.Abs(a.Angle-b.Angle) > 1e-9 {
+			t.Errorf("body %d saved at %.2f, %.2f, want %.2f, %.2f", i, a.X, a.Y, b.X, b.Y)
+		}
+	}
+}
+
+// The spawn is saved where the level put it unless it was edited, wherever
+// the chassis was moved by something else
+func TestSpawnSavedAsAuthored(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(g *Game)
+		edited bool // The spawn follows the chassis
+	}{
+		{"pushed", func(g *Game) {
+			pos := g.car.body.Body.GetPosition()
+			pos.X += 4
+			g.car.body.Body.SetTransform(pos, 0)
+		}, false},
+		{"moved in the editor", func(g *Game) {
+			g.profile.Settings.Snap = SnapSettings{Grid: true, GridSpacing: 1, AngleStep: 15}
+			g.selectBodies([]*GameBody{g.car.body})
+			run(g, tap(pixelgl.KeyRight)...)
+			g.selectBodies(nil)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			startLevel(g, g.config.Levels[0], true)
+			want := g.levelData.spawn()
+
+			tt.edit(g)
+			if tt.edited {
+				pos := g.car.body.Body.GetPosition()
+				want.X, want.Y = pos.X, pos.Y
+			}
+			run(g, InputFrame{}, InputFrame{})
+			spawn := g.editedLevel("test").Spawn
+			if math.Abs(spawn.X-want.X) > 1e-9 || math.Abs(spawn.Y-want.Y) > 1e-9 {
+				t.Errorf("spawn %+v, want %+v", *spawn, want)
+			}
+		})
+	}
+}
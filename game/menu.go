@@ -0,0 +1,519 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"golang.org/x/image/colornames"
+)
+
+const (
+	menuTop         = 260
+	menuItemSpacing = 44
+	menuPanelWidth  = 520
+	menuBottom      = 100 // Space kept free below the items for the hint
+)
+
+var (
+	menuItemStyle     = TextStyle{Font: FontRegular, Size: 26, Color: colornames.Black, Align: AlignCenter}
+	menuSelectedStyle = TextStyle{Font: FontBold, Size: 26, Color: colornames.Darkorange, Align: AlignCenter, Shadow: true}
+	menuPanelColor    = color.RGBA{255, 255, 255, 200}
+)
+
+// Menu is a vertical list of items that can be picked with the keyboard or mouse
+type Menu struct {
+	Selected int
+	panel    *imdraw.IMDraw
+	title    *Label
+	items    []*Label
+	hint     *Label
+	disabled map[int]bool
+	scroll   int // First item shown when they don't all fit
+}
+
+func NewMenu(g *Game, title string, items []string) *Menu {
+	m := &Menu{disabled: map[int]bool{}, panel: imdraw.New(nil)}
+	m.title = NewLabel(g.fonts, HeadingStyle, AnchorTop, pixel.V(0, menuTop-110))
+	fmt.Fprint(m.title, title)
+	m.hint = NewLabel(g.fonts, BodyStyle, AnchorBottom, pixel.V(0, 40))
+	m.SetHint(g.menuHint())
+	m.SetItems(g, items)
+	return m
+}
+
+// menuHint explains the menu controls with their current bindings
+func (g *Game) menuHint() string {
+	return fmt.Sprintf("%s/%s or mouse to choose, %s to select, %s to go back",
+		g.key(ActionMenuUp), g.key(ActionMenuDown), g.key(ActionConfirm), g.key(ActionBack))
+}
+
+func (m *Menu) SetHint(hint string) {
+	m.hint.Clear()
+	fmt.Fprint(m.hint, hint)
+}
+
+// SetItems replaces the item texts, keeping the selection when possible
+func (m *Menu) SetItems(g *Game, items []string) {
+	m.items = nil
+	for i, item := range items {
+		label := NewLabel(g.fonts, menuItemStyle, AnchorTop, pixel.V(0, menuTop+float64(i)*menuItemSpacing))
+		fmt.Fprint(label, item)
+		m.items = append(m.items, label)
+	}
+	if m.Selected >= len(m.items) {
+		m.Selected = len(m.items) - 1
+	}
+}
+
+func (m *Menu) SetDisabled(i int, disabled bool) {
+	m.disabled[i] = disabled
+}
+
+// visible is the range of items that fit on screen, scrolled to show the selection
+func (m *Menu) visible(screen pixel.Rect) (int, int) {
+	// Item offsets are in unscaled pixels, the labels scale them when drawn
+	fit := int((screen.H()/uiScale(screen) - menuTop - menuBottom) / menuItemSpacing)
+	if fit < 1 {
+		fit = 1
+	}
+	if m.Selected < m.scroll {
+		m.scroll = m.Selected
+	}
+	if m.Selected >= m.scroll+fit {
+		m.scroll = m.Selected - fit + 1
+	}
+	if m.scroll > len(m.items)-fit {
+		m.scroll = len(m.items) - fit
+	}
+	if m.scroll < 0 {
+		m.scroll = 0
+	}
+	last := m.scroll + fit
+	if last > len(m.items) {
+		last = len(m.items)
+	}
+	for i := m.scroll; i < last; i++ {
+		m.items[i].Offset.Y = menuTop + float64(i-m.scroll)*menuItemSpacing
+	}
+	return m.scroll, last
+}
+
+// Update handles input and returns the index of the chosen item, or -1
+func (m *Menu) Update(g *Game) int {
+	if len(m.items) == 0 {
+		return -1
+	}
+	if g.repeated(ActionMenuDown) {
+		m.Selected = (m.Selected + 1) % len(m.items)
+	}
+	if g.repeated(ActionMenuUp) {
+		m.Selected = (m.Selected - 1 + len(m.items)) % len(m.items)
+	}
+
+	mouse := g.input.MousePosition()
+	hovered := -1
+	first, last := m.visible(g.screenBounds())
+	for i := first; i < last; i++ {
+		if m.items[i].Bounds(g.screenBounds()).Contains(mouse) {
+			hovered = i
+		}
+	}
+	if hovered >= 0 && g.input.MousePosition() != g.input.MousePreviousPosition() {
+		m.Selected = hovered
+	}
+
+	chosen := -1
+	if g.justPressed(ActionConfirm) {
+		chosen = m.Selected
+	}
+	if g.input.JustPressed(pixelgl.MouseButtonLeft) && hovered >= 0 {
+		m.Selected = hovered
+		chosen = hovered
+	}
+	if chosen >= 0 && m.disabled[chosen] {
+		return -1
+	}
+	return chosen
+}
+
+func (m *Menu) Render(g *Game) {
+	screen := g.screenBounds()
+	first, last := m.visible(screen)
+	s := uiScale(screen)
+	top := screen.Max.Y - (menuTop-150)*s
+	bottom := screen.Max.Y - (menuTop+float64(last-first)*menuItemSpacing)*s
+	center := screen.Center().X
+	width := menuPanelWidth * s
+
+	// Drawn right away rather than with the shared imdraw so it ends up below the text
+	m.panel.Clear()
+	m.panel.Color = menuPanelColor
+	m.panel.Push(pixel.V(center-width/2, bottom), pixel.V(center+width/2, top))
+	m.panel.Rectangle(0)
+	m.panel.Draw(g.Window)
+
+	m.title.Draw(g.Window)
+	for i := first; i < last; i++ {
+		style := menuItemStyle
+		if i == m.Selected {
+			style = menuSelectedStyle
+		}
+		if m.disabled[i] {
+			style.Color = colornames.Gray
+		}
+		m.items[i].Style = style
+		m.items[i].Draw(g.Window)
+	}
+	m.hint.Draw(g.Window)
+}
+
+type MainMenuState struct {
+	menu *Menu
+}
+
+type LevelSelectState struct {
+	menu *Menu
+}
+
+type SettingsState struct {
+	menu *Menu
+}
+
+const (
+	mainMenuPlay = iota
+	mainMenuLevelSelect
+	mainMenuEditor
+	mainMenuSettings
+	mainMenuProfile
+	mainMenuQuit
+)
+
+func (state *MainMenuState) Init(g *Game) {
+	g.showHud = false
+	g.text.Clear()
+	g.sideText.Clear()
+	g.infoText.Clear()
+	state.menu = NewMenu(g, "Physics Game", []string{"Play", "Level Select", "Editor", "Settings", "Profile: " + g.profile.Name, "Quit"})
+}
+
+func (state *MainMenuState) Update(g *Game) {
+	switch state.menu.Update(g) {
+	case mainMenuPlay:
+		g.levelIndex = g.profile.Progress.FirstUnfinished(g.config)
+		g.states.Pop()
+		g.states.Push(LoadingState{levelInfo: g.config.Levels[g.levelIndex]})
+	case mainMenuLevelSelect:
+		g.states.Pop()
+		g.states.Push(&LevelSelectState{})
+	case mainMenuEditor:
+		g.states.Pop()
+		g.states.Push(LoadingState{levelInfo: g.editLevel, edit: true})
+	case mainMenuSettings:
+		g.states.Pop()
+		g.states.Push(&SettingsState{})
+	case mainMenuProfile:
+		g.states.Pop()
+		g.states.Push(&ProfileState{})
+	case mainMenuQuit:
+		g.Window.SetClosed(true)
+	}
+}
+
+func (state *MainMenuState) Render(g *Game) {
+	state.menu.Render(g)
+}
+
+func (state *LevelSelectState) Init(g *Game) {
+	g.showHud = false
+	state.menu = NewMenu(g, "Select Level", nil)
+	if g.leaderboards != nil {
+		state.menu.SetHint(g.menuHint() + ", " + g.key(ActionLeaderboard) + " for leaderboard")
+	}
+	state.refresh(g)
+	state.menu.Selected = g.profile.Progress.FirstUnfinished(g.config)
+}
+
+func (state *LevelSelectState) refresh(g *Game) {
+	var items []string
+	for i, level := range g.config.Levels {
+		item := level.Name
+		if record, completed := g.profile.Progress.Record(level.Filename); completed {
+			item = fmt.Sprintf("%s   Best: %d in %.1f s", level.Name, record.BestScore, record.BestTime)
+		} else if !g.profile.Progress.Unlocked(g.config, i) {
+			item = fmt.Sprintf("%s   (locked)", level.Name)
+		}
+		items = append(items, item)
+	}
+	items = append(items, "Back")
+	state.menu.SetItems(g, items)
+	for i := range g.config.Levels {
+		state.menu.SetDisabled(i, !g.profile.Progress.Unlocked(g.config, i))
+	}
+}
+
+func (state *LevelSelectState) Update(g *Game) {
+	chosen := state.menu.Update(g)
+	if g.justPressed(ActionBack) || chosen == len(g.config.Levels) {
+		g.states.Pop()
+		g.states.Push(&MainMenuState{})
+		return
+	}
+	if chosen >= 0 {
+		g.levelIndex = chosen
+		g.states.Pop()
+		g.states.Push(LoadingState{levelInfo: g.config.Levels[chosen]})
+		return
+	}
+	selected := state.menu.Selected
+	if g.justPressed(ActionLeaderboard) && g.leaderboards != nil && selected < len(g.config.Levels) {
+		g.states.Push(&LeaderboardState{level: g.config.Levels[selected]})
+	}
+}
+
+func (state *LevelSelectState) Render(g *Game) {
+	state.menu.Render(g)
+}
+
+func (state *SettingsState) Init(g *Game) {
+	g.showHud = false
+	state.menu = NewMenu(g, "Settings", nil)
+	state.refresh(g)
+}
+
+const (
+	settingsGrid = iota
+	settingsVSync
+	settingsResolution
+	settingsFullscreen
+	settingsControls
+	settingsBack
+)
+
+func (state *SettingsState) refresh(g *Game) {
+	state.menu.SetItems(g, []string{
+		"Show grid: " + onOff(g.toggleGrid),
+		"VSync: " + onOff(g.Window.VSync()),
+		"Resolution: " + g.profile.Settings.resolution().String(),
+		"Fullscreen: " + onOff(g.profile.Settings.Fullscreen),
+		"Controls",
+		"Back",
+	})
+	// The window size only matters when not fullscreen
+	state.menu.SetDisabled(settingsResolution, g.profile.Settings.Fullscreen)
+}
+
+func (state *SettingsState) Update(g *Game) {
+	chosen := state.menu.Update(g)
+	switch chosen {
+	case settingsGrid:
+		g.toggleGrid = !g.toggleGrid
+	case settingsVSync:
+		g.Window.SetVSync(!g.Window.VSync())
+	case settingsResolution:
+		r := nextResolution(g.profile.Settings.resolution(), pixelgl.PrimaryMonitor())
+		g.profile.Settings.Width, g.profile.Settings.Height = r.Width, r.Height
+		g.applyWindowSettings()
+	case settingsFullscreen:
+		g.toggleFullscreen()
+	}
+	if chosen >= settingsGrid && chosen <= settingsFullscreen {
+		g.saveProfile()
+		state.refresh(g)
+	}
+
+	if chosen == settingsControls {
+		g.states.Pop()
+		g.states.Push(&ControlsState{})
+		return
+	}
+	if g.justPressed(ActionBack) || chosen == settingsBack {
+		g.states.Pop()
+		g.states.Push(&MainMenuState{})
+	}
+}
+
+func (state *SettingsState) Render(g *Game) {
+	state.menu.Render(g)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "On"
+	}
+	return "Off"
+}
+
+// ProfileState lists the saved profiles and lets the player switch to one or
+// type the name of a new one
+type ProfileState struct {
+	menu   *Menu
+	names  []string
+	typing bool
+	name   string
+}
+
+func (state *ProfileState) Init(g *Game) {
+	g.showHud = false
+	state.menu = NewMenu(g, "Profiles", nil)
+	state.refresh(g)
+}
+
+func (state *ProfileState) refresh(g *Game) {
+	state.names = nil
+	if g.profiles != nil {
+		names, err := g.profiles.List()
+		if err != nil {
+			fmt.Println("Listing profiles failed:", err)
+		}
+		state.names = names
+	}
+	if !containsString(state.names, g.profile.Name) {
+		state.names = append(state.names, g.profile.Name)
+	}
+
+	var items []string
+	for _, name := range state.names {
+		if name == g.profile.Name {
+			name = fmt.Sprintf("> %s   %s played", name, formatPlaytime(g.profile.Playtime))
+		}
+		items = append(items, name)
+	}
+	if state.typing {
+		items = append(items, "Name: "+state.name+"_")
+		state.menu.SetHint(fmt.Sprintf("Type a name, %s to create, %s to cancel", g.key(ActionConfirm), g.key(ActionBack)))
+	} else {
+		items = append(items, "New profile")
+		state.menu.SetHint(g.menuHint() + ", " + g.key(ActionDeleteProfile) + " removes a profile")
+	}
+	items = append(items, "Back")
+	state.menu.SetItems(g, items)
+	state.menu.SetDisabled(len(state.names), g.profiles == nil)
+}
+
+func (state *ProfileState) Update(g *Game) {
+	if state.typing {
+		state.updateTyping(g)
+		return
+	}
+
+	chosen := state.menu.Update(g)
+	newProfile := len(state.names)
+	if g.justPressed(ActionBack) || chosen == newProfile+1 {
+		g.states.Pop()
+		g.states.Push(&MainMenuState{})
+		return
+	}
+	if chosen == newProfile {
+		state.typing = true
+		state.name = ""
+		state.refresh(g)
+		return
+	}
+	if chosen >= 0 {
+		g.switchProfile(state.names[chosen])
+		state.refresh(g)
+		return
+	}
+
+	// The active profile can't be removed, switch away from it first
+	selected := state.menu.Selected
+	if g.justPressed(ActionDeleteProfile) && selected < newProfile && state.names[selected] != g.profile.Name {
+		if err := g.profiles.Delete(state.names[selected]); err != nil {
+			fmt.Println("Deleting profile failed:", err)
+		}
+		state.refresh(g)
+	}
+}
+
+func (state *ProfileState) updateTyping(g *Game) {
+	before := state.name
+	state.name += g.input.Typed()
+	if g.input.JustPressed(pixelgl.KeyBackspace) || g.input.Repeated(pixelgl.KeyBackspace) {
+		if runes := []rune(state.name); len(runes) > 0 {
+			state.name = string(runes[:len(runes)-1])
+		}
+	}
+
+	name := strings.TrimSpace(state.name)
+	if g.justPressed(ActionConfirm) && name != "" {
+		state.typing = false
+		g.switchProfile(name)
+		g.saveProfile()
+	}
+	if g.justPressed(ActionBack) {
+		state.typing = false
+	}
+	if state.name != before || !state.typing {
+		state.refresh(g)
+		state.menu.Selected = len(state.names)
+	}
+}
+
+func (state *ProfileState) Render(g *Game) {
+	state.menu.Render(g)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// LeaderboardState shows the best runs on a level on top of the state that
+// opened it, any entry can be picked to watch its replay
+type LeaderboardState struct {
+	level   LevelInfo
+	menu    *Menu
+	board   *Leaderboard
+	showHud bool
+}
+
+func (state *LeaderboardState) Init(g *Game) {
+	state.showHud = g.showHud
+	g.showHud = false
+
+	board, err := g.leaderboards.Load(state.level.Filename)
+	if err != nil {
+		fmt.Println("Loading leaderboard failed:", err)
+		board = &Leaderboard{Level: state.level.Filename}
+	}
+	state.board = board
+
+	var items []string
+	for i, entry := range board.Entries {
+		items = append(items, fmt.Sprintf("%d. %s   %d   %.1f s   %s", i+1, entry.Player, entry.Score, entry.Time, entry.Date.Format("2006-01-02")))
+	}
+	if len(items) == 0 {
+		items = append(items, "No runs yet")
+	}
+	items = append(items, "Back")
+	state.menu = NewMenu(g, "Leaderboard: "+state.level.Name, items)
+	state.menu.SetHint(fmt.Sprintf("%s to watch a replay, %s to go back", g.key(ActionConfirm), g.key(ActionBack)))
+	if len(board.Entries) == 0 {
+		state.menu.SetDisabled(0, true)
+		state.menu.Selected = 1
+	}
+}
+
+func (state *LeaderboardState) Update(g *Game) {
+	chosen := state.menu.Update(g)
+	if g.justPressed(ActionBack) || chosen == len(state.menu.items)-1 {
+		g.showHud = state.showHud
+		g.states.Pop()
+		return
+	}
+	if chosen >= 0 && chosen < len(state.board.Entries) {
+		g.states.Push(&ReplayState{level: state.level, entry: &state.board.Entries[chosen]})
+	}
+}
+
+func (state *LeaderboardState) Render(g *Game) {
+	state.menu.Render(g)
+}
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"golang.org/x/image/colornames"
+)
+
+const (
+	minimapWidth   = 300
+	minimapHeight  = 80
+	minimapMargin  = 10
+	progressHeight = 8
+)
+
+// minimapRect is the screen area of the minimap in the top right corner
+func minimapRect(screen pixel.Rect) pixel.Rect {
+	s := uiScale(screen)
+	max := screen.Max.Sub(pixel.V(minimapMargin, minimapMargin).Scaled(s))
+	return pixel.Rect{Min: max.Sub(pixel.V(minimapWidth, minimapHeight).Scaled(s)), Max: max}
+}
+
+// minimapMatrix maps the level bounds into the minimap, keeping the aspect ratio
+func minimapMatrix(bounds, rect pixel.Rect) pixel.Matrix {
+	scale := math.Min(rect.W()/bounds.W(), rect.H()/bounds.H())
+	offset := pixel.V((rect.W()-bounds.W()*scale)/2, (rect.H()-bounds.H()*scale)/2)
+	return pixel.IM.Moved(bounds.Min.Scaled(-1)).Scaled(pixel.ZV, scale).Moved(rect.Min.Add(offset))
+}
+
+func (g *Game) drawMinimap(imd *imdraw.IMDraw) {
+	rect := minimapRect(g.screenBounds())
+	imd.SetMatrix(pixel.IM)
+	imd.Color = color.RGBA{255, 255, 255, 180}
+	imd.Push(rect.Min, rect.Max)
+	imd.Rectangle(0)
+	imd.Color = colornames.Black
+	imd.Push(rect.Min, rect.Max)
+	imd.Rectangle(1)
+
+	bounds := levelBounds(g)
+	if bounds.Area() == 0 {
+		return
+	}
+	m := minimapMatrix(bounds, rect)
+
+	drawMinimapBodies(imd, m, []*GameBody{g.ground}, colornames.Forestgreen)
+	drawMinimapBodies(imd, m, g.Bodies, colornames.Dimgray)
+	drawMinimapBodies(imd, m, g.CargoBodies, colornames.Darkorange)
+	drawMinimapBodies(imd, m, []*GameBody{g.goalBody}, colornames.Red)
+	drawMinimapBodies(imd, m, g.car.Bodies(), colornames.Blue)
+
+	// Outline of what the camera currently shows
+	view := g.camera.View()
+	imd.Color = colornames.Black
+	imd.Push(m.Project(view.Min), m.Project(view.Max))
+	imd.Rectangle(1)
+}
+
+func drawMinimapBodies(imd *imdraw.IMDraw, m pixel.Matrix, bodies []*GameBody, c color.Color) {
+	imd.Color = c
+	for i := 0; i < len(bodies); i++ {
+		b := bodyBounds(bodies[i])
+		min := m.Project(b.Min)
+		max := m.Project(b.Max)
+		// Keep tiny bodies visible
+		if max.X-min.X < 1 {
+			max.X = min.X + 1
+		}
+		if max.Y-min.Y < 1 {
+			max.Y = min.Y + 1
+		}
+		imd.Push(min, max)
+		imd.Rectangle(0)
+	}
+}
+
+// levelProgress is how far the car has come from the spawn to the goal, from 0 to 1
+func (g *Game) levelProgress() float64 {
+	carX := g.car.body.Body.GetPosition().X
+	goalX := g.goalBody.Body.GetPosition().X
+	distance := g.car.ahead(goalX, g.car.spawn.X)
+	if distance <= 0 {
+		return 1
+	}
+	return math.Max(0, math.Min(1, g.car.ahead(carX, g.car.spawn.X)/distance))
+}
+
+func (g *Game) drawProgress(imd *imdraw.IMDraw) {
+	screen := g.screenBounds()
+	s := uiScale(screen)
+	rect := minimapRect(screen)
+	bar := pixel.R(rect.Min.X, rect.Min.Y-(minimapMargin+progressHeight)*s, rect.Max.X, rect.Min.Y-minimapMargin*s)
+	progress := g.levelProgress()
+
+	imd.SetMatrix(pixel.IM)
+	imd.Color = color.RGBA{255, 255, 255, 180}
+	imd.Push(bar.Min, bar.Max)
+	imd.Rectangle(0)
+	imd.Color = colornames.Forestgreen
+	imd.Push(bar.Min, pixel.V(bar.Min.X+bar.W()*progress, bar.Max.Y))
+	imd.Rectangle(0)
+	imd.Color = colornames.Black
+	imd.Push(bar.Min, bar.Max)
+	imd.Rectangle(1)
+
+	remaining := g.car.ahead(g.goalBody.Body.GetPosition().X, g.car.body.Body.GetPosition().X)
+	g.progressText.Clear()
+	g.progressText.Orig = pixel.ZV
+	g.progressText.Dot = g.progressText.Orig
+	fmt.Fprintf(g.progressText, "%.0f m to goal", math.Max(0, remaining))
+	g.progressText.Draw(g.Window, pixel.IM.Scaled(pixel.ZV, s).Moved(pixel.V(bar.Min.X, bar.Min.Y-16*s)))
+}
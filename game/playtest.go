@@ -0,0 +1,82 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/faiface/pixel"
+)
+
+// PlayTest keeps the editor while the edited level is played, so it can be
+// put back exactly as it was. Every play test and restart starts from the
+// level as it was edited.
+type PlayTest struct {
+	level     *LevelData
+	original  *LevelData // Level data before the test
+	history   EditHistory
+	selection []bodyRef
+	camera    Camera
+}
+
+// startPlayTest plays the edited level in a fresh world
+func (g *Game) startPlayTest() {
+	test := &PlayTest{original: g.levelData, history: g.editHistory, camera: *g.camera}
+	for _, body := range g.selection() {
+		if ref, ok := g.refOf(body); ok {
+			test.selection = append(test.selection, ref)
+		}
+	}
+	g.leaveEditor()
+	level := g.editedLevel(g.editLevel.Name)
+	test.level = &level
+
+	g.levelData = test.level
+	g.buildWorld(g.car.def)
+	g.playTest = test
+
+	g.camera.Zoom = 1
+	carPos := g.car.body.Body.GetPosition()
+	g.camera.SnapTo(pixel.V(carPos.X, carPos.Y))
+	g.states.Pop()
+	g.states.Push(PlayState{})
+}
+
+// endPlayTest goes back to the editor as it was when the test started
+func (g *Game) endPlayTest() {
+	test := g.playTest
+	g.playTest = nil
+	g.buildWorld(g.car.def)
+	g.levelData = test.original
+	g.editHistory = test.history
+	*g.camera = test.camera
+
+	g.states.Pop()
+	g.states.Push(EditState{})
+	var selected []*GameBody
+	for _, ref := range test.selection {
+		selected = append(selected, g.bodyAt(ref))
+	}
+	if len(selected) > 0 {
+		g.selectBodies(selected)
+	}
+}
+
+// finishPlayTest ends a play test that reached the goal
+func (g *Game) finishPlayTest() {
+	score, duration := g.CalcScore(), g.replay.Duration()
+	g.endPlayTest()
+	g.editorMessage("Play test reached the goal in %.1f s, score %d", duration, score)
+}
+
+// editorHistory is the history of the level in the editor, kept aside
+// during a play test
+func (g *Game) editorHistory() *EditHistory {
+	if g.playTest != nil {
+		return &g.playTest.history
+	}
+	return &g.editHistory
+}
+
+// playTestHelp is added to the help of the play states during a play test
+func (g *Game) playTestHelp() string {
+	return fmt.Sprintf("Play test, %s to return to the editor", g.key(ActionToggleEdit))
+}
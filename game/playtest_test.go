@@ -0,0 +1,43 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+func TestPlayTestReturnsToEditor(t *testing.T) {
+	g := newTestGame(t)
+	startLevel(g, g.config.Levels[0], true)
+	bodies := len(g.Bodies)
+
+	run(g, tap(pixelgl.KeyE)...)
+	if got := stateName(g.states.Top()); got != "game.PlayState" || g.playTest == nil {
+		t.Fatalf("state %s, play test %v, want a play test", got, g.playTest != nil)
+	}
+	run(g, tap(pixelgl.KeyE)...)
+	if got := stateName(g.states.Top()); got != "game.EditState" || g.playTest != nil {
+		t.Fatalf("state %s, play test %v, want the editor", got, g.playTest != nil)
+	}
+	if len(g.Bodies) != bodies {
+		t.Errorf("%d bodies after the play test, want %d", len(g.Bodies), bodies)
+	}
+}
+
+// The camera of a play test stays within the level as edited, not as loaded
+func TestPlayTestCameraBounds(t *testing.T) {
+	g := newTestGame(t)
+	startLevel(g, g.config.Levels[0], true)
+	loaded := g.camera.Bounds
+	g.selectBodies([]*GameBody{g.ground})
+	g.inspector.show(g.inspected(), nil)
+	g.inspector.apply(g, widthField, func(b *GameBody) float64 { return b.HalfW*2 + 40 })
+
+	run(g, tap(pixelgl.KeyE)...)
+	if g.playTest == nil {
+		t.Fatal("no play test")
+	}
+	if g.camera.Bounds.Max.X <= loaded.Max.X {
+		t.Errorf("camera bounds end at %.1f, want past %.1f for the longer ground", g.camera.Bounds.Max.X, loaded.Max.X)
+	}
+}
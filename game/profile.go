@@ -0,0 +1,258 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	profileAppDir      = "physics-game"
+	profileCurrentFile = "current"
+	DefaultProfileName = "Player"
+)
+
+// ProfileSettings are the options from the settings menu that follow a profile
+type ProfileSettings struct {
+	ShowGrid   bool
+	VSync      bool
+	Width      int `json:",omitempty"` // Window size when not fullscreen, 0 for the default
+	Height     int `json:",omitempty"`
+	Fullscreen bool
+	Snap       SnapSettings        // Editor snapping
+	Bindings   map[Action][]string `json:",omitempty"` // Changes to the configured bindings
+}
+
+// Profile is everything remembered about one player between sessions
+type Profile struct {
+	Name     string
+	Progress *Progress
+	Playtime float64 // Seconds spent in the game
+	Settings ProfileSettings
+}
+
+func NewProfile(name string) *Profile {
+	return &Profile{Name: name, Progress: NewProgress(), Settings: ProfileSettings{VSync: true, Snap: DefaultSnapSettings()}}
+}
+
+// ProfileStore keeps profiles as json files in the user config directory
+type ProfileStore struct {
+	dir string
+}
+
+func NewProfileStore() (*ProfileStore, error) {
+	dir, err := appConfigDir("profiles")
+	if err != nil {
+		return nil, err
+	}
+	return &ProfileStore{dir: dir}, nil
+}
+
+// List returns the names of all saved profiles in alphabetical order
+func (s *ProfileStore) List() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, path := range paths {
+		profile, err := readProfile(path)
+		if err != nil {
+			continue
+		}
+		names = append(names, profile.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads the named profile, a profile that was never saved starts out empty
+func (s *ProfileStore) Load(name string) (*Profile, error) {
+	profile, err := readProfile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewProfile(name), nil
+	}
+	return profile, err
+}
+
+func (s *ProfileStore) Save(profile *Profile) error {
+	data, err := json.MarshalIndent(profile, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(profile.Name), data)
+}
+
+func (s *ProfileStore) Delete(name string) error {
+	return os.Remove(s.path(name))
+}
+
+// Current is the name of the last used profile
+func (s *ProfileStore) Current() string {
+	data, err := os.ReadFile(filepath.Join(s.dir, profileCurrentFile))
+	name := strings.TrimSpace(string(data))
+	if err != nil || name == "" {
+		return DefaultProfileName
+	}
+	return name
+}
+
+func (s *ProfileStore) SetCurrent(name string) error {
+	return writeFileAtomic(filepath.Join(s.dir, profileCurrentFile), []byte(name))
+}
+
+func (s *ProfileStore) path(name string) string {
+	return filepath.Join(s.dir, safeFilename(name)+".json")
+}
+
+func readProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profile := NewProfile("")
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, err
+	}
+	if profile.Progress == nil || profile.Progress.Levels == nil {
+		profile.Progress = NewProgress()
+	}
+	return profile, nil
+}
+
+// appConfigDir is a directory of the game inside the user config directory,
+// created when missing
+func appConfigDir(name string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, profileAppDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// safeFilename turns a name into a file name that is safe on every platform
+func safeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// writeFileAtomic writes to a temporary file next to path and renames it into
+// place, so a crash halfway through never leaves a truncated file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadProfile opens the last used profile, falling back to one that only
+// lives in memory when the config directory can't be used
+func (g *Game) loadProfile() {
+	store, err := NewProfileStore()
+	if err != nil {
+		fmt.Println("Profiles unavailable:", err)
+		g.profile = NewProfile(DefaultProfileName)
+		g.applySettings()
+		return
+	}
+	g.profiles = store
+	g.switchProfile(store.Current())
+}
+
+// switchProfile saves the active profile and makes the named one active
+func (g *Game) switchProfile(name string) {
+	if g.profile != nil {
+		g.saveProfile()
+	}
+	profile, err := g.profiles.Load(name)
+	if err != nil {
+		fmt.Println("Loading profile failed:", err)
+		profile = NewProfile(name)
+	}
+	g.profile = profile
+	if err := g.profiles.SetCurrent(name); err != nil {
+		fmt.Println("Saving profile failed:", err)
+	}
+	g.applySettings()
+}
+
+func (g *Game) applySettings() {
+	g.toggleGrid = g.profile.Settings.ShowGrid
+	// Profiles from before snapping was added
+	if snap := g.profile.Settings.Snap; snap.GridSpacing <= 0 || snap.AngleStep <= 0 {
+		g.profile.Settings.Snap = DefaultSnapSettings()
+	}
+	if g.Window != nil {
+		g.Window.SetVSync(g.profile.Settings.VSync)
+	}
+	g.applyWindowSettings()
+	g.loadActions()
+}
+
+// saveProfile writes the active profile along with the current settings
+func (g *Game) saveProfile() {
+	g.trackPlaytime()
+	g.profile.Settings.ShowGrid = g.toggleGrid
+	if g.Window != nil {
+		g.profile.Settings.VSync = g.Window.VSync()
+		// Remember a window resized by dragging its edges
+		if g.Window.Monitor() == nil {
+			bounds := g.Window.Bounds()
+			g.profile.Settings.Width = int(bounds.W())
+			g.profile.Settings.Height = int(bounds.H())
+		}
+	}
+	if g.profiles == nil {
+		return
+	}
+	if err := g.profiles.Save(g.profile); err != nil {
+		fmt.Println("Saving profile failed:", err)
+	}
+}
+
+// trackPlaytime adds the time since the last call to the profile
+func (g *Game) trackPlaytime() {
+	now := time.Now()
+	if !g.lastPlaytime.IsZero() {
+		g.profile.Playtime += now.Sub(g.lastPlaytime).Seconds()
+	}
+	g.lastPlaytime = now
+}
+
+// Close saves everything that should outlive the session
+func (g *Game) Close() {
+	g.saveProfile()
+}
+
+func formatPlaytime(seconds float64) string {
+	minutes := int(seconds / 60)
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
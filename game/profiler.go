@@ -0,0 +1,266 @@
+package game
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+const (
+	profilerHistory     = 300 // Frames kept for the graph, 5 seconds at 60 fps
+	profilerGraphWidth  = 300
+	profilerGraphHeight = 100
+	profilerGraphMaxMs  = 33.3 // Top of the graph
+)
+
+type ProfileSection int
+
+const (
+	SectionStep ProfileSection = iota
+	SectionInput
+	SectionUpdate
+	SectionRender
+)
+
+type FrameSample struct {
+	Frame    time.Duration
+	Step     time.Duration
+	Input    time.Duration // Global shortcuts, camera and editor input
+	Update   time.Duration // Input and game logic, including Step
+	Render   time.Duration
+	Bodies   int
+	Contacts int
+	Joints   int
+}
+
+// Profiler keeps timings for the last frames and can record them to a CSV file
+type Profiler struct {
+	samples    [profilerHistory]FrameSample
+	next       int
+	count      int
+	frames     int
+	current    FrameSample
+	frameStart time.Time
+	file       *os.File
+	csv        *csv.Writer
+}
+
+func (p *Profiler) BeginFrame() {
+	p.frameStart = time.Now()
+	p.current = FrameSample{}
+}
+
+// Add accounts d to a section of the current frame
+func (p *Profiler) Add(section ProfileSection, d time.Duration) {
+	switch section {
+	case SectionStep:
+		p.current.Step += d
+	case SectionInput:
+		p.current.Input += d
+	case SectionUpdate:
+		p.current.Update += d
+	case SectionRender:
+		p.current.Render += d
+	}
+}
+
+func (p *Profiler) EndFrame(world *box2d.B2World) {
+	if p.frameStart.IsZero() {
+		return
+	}
+	p.current.Frame = time.Since(p.frameStart)
+	p.current.Bodies = world.GetBodyCount()
+	p.current.Contacts = world.GetContactCount()
+	p.current.Joints = world.GetJointCount()
+
+	p.samples[p.next] = p.current
+	p.next = (p.next + 1) % profilerHistory
+	if p.count < profilerHistory {
+		p.count++
+	}
+	p.frames++
+
+	if p.csv != nil {
+		p.writeSample(p.current)
+	}
+}
+
+// Sample returns the i:th newest frame, 0 being the last finished one
+func (p *Profiler) Sample(i int) FrameSample {
+	return p.samples[(p.next-1-i+profilerHistory*2)%profilerHistory]
+}
+
+// Average returns the mean of the last n frames
+func (p *Profiler) Average(n int) FrameSample {
+	if n > p.count {
+		n = p.count
+	}
+	avg := FrameSample{}
+	if n == 0 {
+		return avg
+	}
+	for i := 0; i < n; i++ {
+		s := p.Sample(i)
+		avg.Frame += s.Frame
+		avg.Step += s.Step
+		avg.Input += s.Input
+		avg.Update += s.Update
+		avg.Render += s.Render
+	}
+	avg.Frame /= time.Duration(n)
+	avg.Step /= time.Duration(n)
+	avg.Input /= time.Duration(n)
+	avg.Update /= time.Duration(n)
+	avg.Render /= time.Duration(n)
+	last := p.Sample(0)
+	avg.Bodies, avg.Contacts, avg.Joints = last.Bodies, last.Contacts, last.Joints
+	return avg
+}
+
+func (p *Profiler) Recording() bool {
+	return p.csv != nil
+}
+
+// StartRecording writes every following frame to a new CSV file
+func (p *Profiler) StartRecording() error {
+	filename := fmt.Sprintf("profile_%s.csv", time.Now().Format("20060102_150405"))
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	p.file = file
+	p.csv = csv.NewWriter(file)
+	p.frames = 0
+	fmt.Println("Recording profile to", filename)
+	return p.csv.Write([]string{"frame", "frame_ms", "step_ms", "input_ms", "logic_ms", "render_ms", "bodies", "contacts", "joints"})
+}
+
+func (p *Profiler) StopRecording() error {
+	if p.csv == nil {
+		return nil
+	}
+	p.csv.Flush()
+	err := p.csv.Error()
+	if closeErr := p.file.Close(); err == nil {
+		err = closeErr
+	}
+	p.csv = nil
+	p.file = nil
+	fmt.Println("Profile saved!")
+	return err
+}
+
+func (p *Profiler) writeSample(s FrameSample) {
+	p.csv.Write([]string{
+		strconv.Itoa(p.frames),
+		formatMs(s.Frame),
+		formatMs(s.Step),
+		formatMs(s.Input),
+		formatMs(s.Logic()),
+		formatMs(s.Render),
+		strconv.Itoa(s.Bodies),
+		strconv.Itoa(s.Contacts),
+		strconv.Itoa(s.Joints),
+	})
+}
+
+// Logic is the part of Update spent neither stepping the world nor handling
+// input
+func (s FrameSample) Logic() time.Duration {
+	return s.Update - s.Step - s.Input
+}
+
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(ms(d), 'f', 3, 64)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Draw shows the averages of the last second and a graph of the whole history
+func (p *Profiler) Draw(g *Game, imd *imdraw.IMDraw, txt *text.Text) {
+	avg := p.Average(60)
+	fps := 0.0
+	if avg.Frame > 0 {
+		fps = float64(time.Second) / float64(avg.Frame)
+	}
+
+	screen := g.screenBounds()
+	origin := pixel.V(screen.Min.X+minimapMargin, screen.Max.Y-180)
+	graph := pixel.R(origin.X, origin.Y-profilerGraphHeight, origin.X+profilerGraphWidth, origin.Y)
+
+	txt.Clear()
+	txt.Orig = pixel.V(graph.Max.X+minimapMargin, origin.Y-10)
+	txt.Dot = txt.Orig
+	fmt.Fprintf(txt, "FPS: %.0f\n", fps)
+	fmt.Fprintf(txt, "Frame: %.2f ms\n", ms(avg.Frame))
+	fmt.Fprintf(txt, "Step: %.2f ms\n", ms(avg.Step))
+	fmt.Fprintf(txt, "Input: %.2f ms\n", ms(avg.Input))
+	fmt.Fprintf(txt, "Logic: %.2f ms\n", ms(avg.Logic()))
+	fmt.Fprintf(txt, "Render: %.2f ms\n", ms(avg.Render))
+	fmt.Fprintf(txt, "Bodies: %d\n", avg.Bodies)
+	fmt.Fprintf(txt, "Contacts: %d\n", avg.Contacts)
+	fmt.Fprintf(txt, "Joints: %d\n", avg.Joints)
+	if p.Recording() {
+		fmt.Fprintf(txt, "Recording CSV (%s to stop)\n", g.key(ActionToggleRecording))
+	} else {
+		fmt.Fprintf(txt, "%s to record CSV\n", g.key(ActionToggleRecording))
+	}
+	txt.Draw(g.Window, pixel.IM)
+
+	imd.SetMatrix(pixel.IM)
+	imd.Color = pixel.RGBA{R: 1, G: 1, B: 1, A: 0.7}
+	imd.Push(graph.Min, graph.Max)
+	imd.Rectangle(0)
+
+	// One stacked bar per frame, newest on the right
+	barW := graph.W() / profilerHistory
+	msToY := graph.H() / profilerGraphMaxMs
+	for i := 0; i < p.count; i++ {
+		s := p.Sample(i)
+		x := graph.Max.X - float64(i+1)*barW
+		y := graph.Min.Y
+		sections := []struct {
+			d time.Duration
+			c pixel.RGBA
+		}{
+			{s.Step, pixel.ToRGBA(colornames.Royalblue)},
+			{s.Input, pixel.ToRGBA(colornames.Mediumpurple)},
+			{s.Logic(), pixel.ToRGBA(colornames.Seagreen)},
+			{s.Render, pixel.ToRGBA(colornames.Darkorange)},
+			{s.Frame - s.Update - s.Render, pixel.ToRGBA(colornames.Lightgray)},
+		}
+		for _, section := range sections {
+			h := ms(section.d) * msToY
+			if h <= 0 {
+				continue
+			}
+			top := y + h
+			if top > graph.Max.Y {
+				top = graph.Max.Y
+			}
+			imd.Color = section.c
+			imd.Push(pixel.V(x, y), pixel.V(x+barW, top))
+			imd.Rectangle(0)
+			y = top
+		}
+	}
+
+	// 60 fps budget
+	budget := graph.Min.Y + 1000.0/60.0*msToY
+	imd.Color = colornames.Red
+	imd.Push(pixel.V(graph.Min.X, budget), pixel.V(graph.Max.X, budget))
+	imd.Line(1)
+	imd.Color = colornames.Black
+	imd.Push(graph.Min, graph.Max)
+	imd.Rectangle(1)
+}
@@ -0,0 +1,27 @@
+package game
+
+import "testing"
+
+// handleInput is timed on its own, apart from the rest of Update
+func TestProfilerInput(t *testing.T) {
+	g := newTestGame(t)
+	playLevel(g, g.config.Levels[0])
+
+	g.Profiler.BeginFrame()
+	run(g, InputFrame{})
+	g.Profiler.EndFrame(g.World)
+
+	s := g.Profiler.Sample(0)
+	if s.Input <= 0 {
+		t.Errorf("input %v, want it timed", s.Input)
+	}
+	if s.Step <= 0 {
+		t.Errorf("step %v, want it timed", s.Step)
+	}
+	if logic := s.Logic(); logic < 0 || logic > s.Update {
+		t.Errorf("logic %v outside of update %v", logic, s.Update)
+	}
+	if avg := g.Profiler.Average(60); avg.Input != s.Input {
+		t.Errorf("average input %v, want %v", avg.Input, s.Input)
+	}
+}
@@ -0,0 +1,58 @@
+package game
+
+// LevelRecord is the best result on a level, keyed by level filename
+type LevelRecord struct {
+	Completed bool
+	BestScore int
+	BestTime  float64 // Seconds of simulated time
+}
+
+// Progress tracks which levels have been beaten and the best scores on them
+type Progress struct {
+	Levels map[string]LevelRecord
+}
+
+func NewProgress() *Progress {
+	return &Progress{Levels: map[string]LevelRecord{}}
+}
+
+// Complete records a finished level and reports whether the score is a new best
+func (p *Progress) Complete(filename string, score int, seconds float64) bool {
+	record, ok := p.Levels[filename]
+	first := !ok || !record.Completed
+	newBest := first || score > record.BestScore
+	record.Completed = true
+	if newBest {
+		record.BestScore = score
+	}
+	if first || seconds < record.BestTime {
+		record.BestTime = seconds
+	}
+	p.Levels[filename] = record
+	return newBest
+}
+
+func (p *Progress) Record(filename string) (LevelRecord, bool) {
+	record, ok := p.Levels[filename]
+	return record, ok && record.Completed
+}
+
+// Unlocked reports whether level i of the config can be played. The first
+// level is always open and each finished level opens the next one.
+func (p *Progress) Unlocked(config *ConfigData, i int) bool {
+	if i <= 0 {
+		return true
+	}
+	_, completed := p.Record(config.Levels[i-1].Filename)
+	return completed
+}
+
+// FirstUnfinished is the first unlocked level that hasn't been beaten yet
+func (p *Progress) FirstUnfinished(config *ConfigData) int {
+	for i := 0; i < len(config.Levels); i++ {
+		if _, completed := p.Record(config.Levels[i].Filename); !completed {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// PromptState asks a question over the current state, which is paused until
+// it is answered. With Choices one of them is picked, otherwise an answer is
+// typed, starting from Text. The prompt is gone when the callbacks run, so
+// they can push another one.
+type PromptState struct {
+	Title    string
+	Choices  []string
+	Label    string // Shown before the typed text
+	Text     string
+	OnChoice func(g *Game, choice int)
+	OnText   func(g *Game, text string)
+	OnCancel func(g *Game)
+
+	menu *Menu
+}
+
+func (state *PromptState) Init(g *Game) {
+	state.menu = NewMenu(g, state.Title, nil)
+	state.refresh(g)
+	// The menu was made without items, which leaves nothing selected
+	state.menu.Selected = 0
+}
+
+func (state *PromptState) refresh(g *Game) {
+	if state.Choices != nil {
+		state.menu.SetItems(g, state.Choices)
+		return
+	}
+	state.menu.SetItems(g, []string{state.Label + ": " + state.Text + "_"})
+	state.menu.SetHint(fmt.Sprintf("Type, %s to confirm, %s to cancel", g.key(ActionConfirm), g.key(ActionBack)))
+}
+
+func (state *PromptState) Update(g *Game) {
+	if g.justPressed(ActionBack) {
+		g.states.Pop()
+		if state.OnCancel != nil {
+			state.OnCancel(g)
+		}
+		return
+	}
+
+	if state.Choices != nil {
+		if chosen := state.menu.Update(g); chosen >= 0 {
+			g.states.Pop()
+			if state.OnChoice != nil {
+				state.OnChoice(g, chosen)
+			}
+		}
+		return
+	}
+
+	before := state.Text
+	state.Text += g.input.Typed()
+	if g.input.JustPressed(pixelgl.KeyBackspace) || g.input.Repeated(pixelgl.KeyBackspace) {
+		if runes := []rune(state.Text); len(runes) > 0 {
+			state.Text = string(runes[:len(runes)-1])
+		}
+	}
+	if g.justPressed(ActionConfirm) {
+		g.states.Pop()
+		if state.OnText != nil {
+			state.OnText(g, state.Text)
+		}
+		return
+	}
+	if state.Text != before {
+		state.refresh(g)
+	}
+}
+
+func (state *PromptState) Render(g *Game) {
+	state.menu.Render(g)
+}
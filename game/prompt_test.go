@@ -0,0 +1,38 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Global shortcuts are typed as text while a prompt or the inspector takes it
+func TestShortcutsWhileTyping(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *Game)
+		grid  bool
+	}{
+		{"editor", func(g *Game) {}, true},
+		{"prompt", func(g *Game) { run(g, tap(pixelgl.KeyLeftShift, pixelgl.KeyS)...) }, false},
+		{"inspector", func(g *Game) {
+			g.selectBodies([]*GameBody{g.Bodies[0]})
+			run(g, InputFrame{})
+			g.inspector.focus = "X"
+			run(g, tap(pixelgl.KeyEnter)...)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			startLevel(g, g.config.Levels[0], true)
+			tt.setup(g)
+			grid := g.toggleGrid
+
+			run(g, InputFrame{Pressed: []pixelgl.Button{pixelgl.KeyM}, Typed: "m"}, InputFrame{})
+			if toggled := g.toggleGrid != grid; toggled != tt.grid {
+				t.Errorf("grid toggled %v, want %v", toggled, tt.grid)
+			}
+		})
+	}
+}
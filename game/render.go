@@ -1,6 +1,9 @@
 package game
 
 import (
+	"image/color"
+	"math"
+
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
@@ -8,10 +11,10 @@ import (
 )
 
 func (body *GameBody) Render(g *Game, win *pixelgl.Window, imd *imdraw.IMDraw) {
+	textured := body.renderTexture(g, win)
+
 	pos := body.Body.GetPosition()
-	pos.OperatorScalarMulInplace(float64(Scale))
-	m := pixel.IM.Rotated(pixel.V(0, 0), body.Body.GetAngle())
-	m = m.Moved(pixel.V(pos.X-g.camera.X*Scale, pos.Y))
+	m := g.camera.BodyMatrix(*toPixelVec(&pos), body.Body.GetAngle())
 	imd.SetMatrix(m)
 	imd.EndShape = imdraw.RoundEndShape
 
@@ -20,8 +23,10 @@ func (body *GameBody) Render(g *Game, win *pixelgl.Window, imd *imdraw.IMDraw) {
 		imd.Color = colornames.Blueviolet
 		p1 := pixel.V(-body.HalfW*float64(Scale), -body.HalfH*float64(Scale))
 		p2 := pixel.V(body.HalfW*float64(Scale), body.HalfH*float64(Scale))
-		imd.Push(p1, p2)
-		imd.Rectangle(3)
+		if !textured {
+			imd.Push(p1, p2)
+			imd.Rectangle(3)
+		}
 
 		if body.IsSelected {
 			p1.X -= 3
@@ -33,22 +38,51 @@ func (body *GameBody) Render(g *Game, win *pixelgl.Window, imd *imdraw.IMDraw) {
 			imd.Rectangle(3)
 		}
 	case Circle:
-		imd.Color = colornames.Brown
-		imd.Push(pixel.V(0, 0))
-		imd.Circle(body.Radius*Scale, 3)
-		imd.Push(pixel.V(0, 0), pixel.V(0, body.Radius*Scale))
-		imd.Line(3)
+		if !textured {
+			imd.Color = colornames.Brown
+			imd.Push(pixel.V(0, 0))
+			imd.Circle(body.Radius*Scale, 3)
+			imd.Push(pixel.V(0, 0), pixel.V(0, body.Radius*Scale))
+			imd.Line(3)
+		}
+
+		if body.IsSelected {
+			imd.Color = colornames.Darkorange
+			imd.Push(pixel.V(0, 0))
+			imd.Circle(body.Radius*Scale+3, 3)
+		}
+	case Polygon:
+		imd.Color = colornames.Blueviolet
+		for i := 0; i < len(body.Vertices); i++ {
+			imd.Push(pixel.V(body.Vertices[i].X*Scale, body.Vertices[i].Y*Scale))
+		}
+		imd.Polygon(3)
+
+		if body.IsSelected {
+			imd.Color = colornames.Darkorange
+			for i := 0; i < len(body.Vertices); i++ {
+				v := pixel.V(body.Vertices[i].X*Scale, body.Vertices[i].Y*Scale)
+				imd.Push(v.Add(v.Unit().Scaled(3)))
+			}
+			imd.Polygon(3)
+		}
 	}
 }
 
 func (g *Game) Draw(win *pixelgl.Window, imd *imdraw.IMDraw) {
+	for i := 0; i < len(g.backgrounds); i++ {
+		g.backgrounds[i].Draw(win, g.camera)
+	}
+
 	if g.toggleGrid {
-		DrawGrid(imd)
+		DrawGrid(imd, g.camera, g.profile.Settings.Snap.GridSpacing)
 	}
 
 	g.goalBody.Render(g, win, imd)
 
-	g.scoreText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 3))
+	if g.showHud {
+		g.scoreText.Draw(g.Window)
+	}
 
 	// Render bodies
 	for i := 0; i < len(g.Bodies); i++ {
@@ -60,33 +94,71 @@ func (g *Game) Draw(win *pixelgl.Window, imd *imdraw.IMDraw) {
 	}
 
 	// Render Car
-	g.car.body.Render(g, win, imd)
-	g.car.wheel1.Render(g, win, imd)
-	g.car.wheel2.Render(g, win, imd)
+	carBodies := g.car.Bodies()
+	for i := 0; i < len(carBodies); i++ {
+		carBodies[i].Render(g, win, imd)
+	}
 
 	// Render floor
 	g.ground.Render(g, win, imd)
-	pos := g.ground.Body.GetPosition()
-	startX := (pos.X-g.ground.HalfW)*Scale + g.groundSprite.Frame().W()/2
-	y := (pos.Y+g.ground.HalfH)*Scale - g.groundSprite.Frame().H()/2
-	spriteW := g.groundSprite.Frame().W()
-	repeat := int(g.ground.HalfW * 2 * Scale / spriteW)
-
-	for i := 0; i < repeat; i++ {
-		g.groundSprite.Draw(win, pixel.IM.Moved(pixel.V(startX+float64(i)*spriteW-g.camera.X*Scale, y)))
+	if g.groundSprite != nil {
+		pos := g.ground.Body.GetPosition()
+		startX := (pos.X-g.ground.HalfW)*Scale + g.groundSprite.Frame().W()/2
+		y := (pos.Y+g.ground.HalfH)*Scale - g.groundSprite.Frame().H()/2
+		spriteW := g.groundSprite.Frame().W()
+		repeat := int(g.ground.HalfW * 2 * Scale / spriteW)
+
+		for i := 0; i < repeat; i++ {
+			spritePos := pixel.V(startX+float64(i)*spriteW, y).Scaled(InvScale)
+			g.groundSprite.Draw(win, g.camera.BodyMatrix(spritePos, 0))
+		}
+	}
+
+	if g.toggleDebug {
+		g.drawDebug(imd)
+	}
+
+	if g.showHud {
+		g.drawMinimap(imd)
+		g.drawProgress(imd)
+	}
+
+	if g.toggleStats {
+		g.Profiler.Draw(g, imd, g.statsText)
 	}
 
 	g.states.Top().Render(g)
 }
 
-func DrawGrid(imd *imdraw.IMDraw) {
+const minGridPixels = 8 // Closest grid lines are drawn on screen
+
+var gridColor = color.RGBA{0, 0, 0, 60}
+
+// DrawGrid draws world space lines spacing meters apart over the camera
+// view. When zoomed out far every other line is skipped until they are a
+// readable distance apart. Lines through multiples of five are thicker.
+func DrawGrid(imd *imdraw.IMDraw, cam *Camera, spacing float64) {
+	for spacing*cam.PixelsPerMeter() < minGridPixels {
+		spacing *= 2
+	}
+	view := cam.View()
 	imd.SetMatrix(pixel.IM)
-	for i := 1; i < 10; i++ {
-		imd.Push(pixel.V(float64(i)*Scale, 0), pixel.V(float64(i)*Scale, 7.5*Scale))
-		imd.Line(3)
+	imd.Color = gridColor
+
+	width := func(i float64) float64 {
+		if math.Mod(math.Abs(i), 5) == 0 {
+			return 3
+		}
+		return 1
+	}
+	for i := math.Ceil(view.Min.X / spacing); i*spacing <= view.Max.X; i++ {
+		x := i * spacing
+		imd.Push(cam.WorldToScreen(pixel.V(x, view.Min.Y)), cam.WorldToScreen(pixel.V(x, view.Max.Y)))
+		imd.Line(width(i))
 	}
-	for i := 1; i < 8; i++ {
-		imd.Push(pixel.V(0, float64(i)*Scale), pixel.V(float64(10)*Scale, float64(i)*Scale))
-		imd.Line(3)
+	for i := math.Ceil(view.Min.Y / spacing); i*spacing <= view.Max.Y; i++ {
+		y := i * spacing
+		imd.Push(cam.WorldToScreen(pixel.V(view.Min.X, y)), cam.WorldToScreen(pixel.V(view.Max.X, y)))
+		imd.Line(width(i))
 	}
 }
@@ -0,0 +1,211 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bytearena/box2d"
+)
+
+// Controls are the car inputs held during one time step
+type Controls uint8
+
+const (
+	ControlForward Controls = 1 << iota
+	ControlBackward
+	ControlBrake
+	ControlReset
+)
+
+// ControlRun is a number of steps in a row with the same controls
+type ControlRun struct {
+	Controls Controls
+	Steps    int
+}
+
+// ReplayForce is a mouse drag force applied after step Step. Body indexes the
+// car bodies followed by the level bodies.
+type ReplayForce struct {
+	Step  int
+	Body  int
+	Local box2d.B2Vec2
+	Force box2d.B2Vec2
+}
+
+// Replay holds every input of a run on a level, enough to simulate it again
+type Replay struct {
+	Level    string
+	Vehicle  *VehicleDef
+	Controls []ControlRun
+	Forces   []ReplayForce `json:",omitempty"`
+	steps    int
+}
+
+// ReplayResult is how a run ended
+type ReplayResult struct {
+	ReachedGoal bool
+	Score       int
+	Time        float64
+}
+
+func NewReplay(level string, vehicle *VehicleDef) *Replay {
+	return &Replay{Level: level, Vehicle: vehicle}
+}
+
+// Record adds one step with the given controls
+func (r *Replay) Record(controls Controls) {
+	r.steps++
+	if n := len(r.Controls); n > 0 && r.Controls[n-1].Controls == controls {
+		r.Controls[n-1].Steps++
+		return
+	}
+	r.Controls = append(r.Controls, ControlRun{Controls: controls, Steps: 1})
+}
+
+// RecordForce adds a force applied after the last recorded step
+func (r *Replay) RecordForce(body int, local, force box2d.B2Vec2) {
+	r.Forces = append(r.Forces, ReplayForce{Step: r.Steps() - 1, Body: body, Local: local, Force: force})
+}
+
+// Steps is the number of recorded time steps
+func (r *Replay) Steps() int {
+	if r.steps == 0 {
+		for _, run := range r.Controls {
+			r.steps += run.Steps
+		}
+	}
+	return r.steps
+}
+
+// Duration is the recorded simulation time in seconds
+func (r *Replay) Duration() float64 {
+	return float64(r.Steps()) * TimeStep
+}
+
+// ReplayPlayer feeds the inputs of a replay one step at a time
+type ReplayPlayer struct {
+	replay *Replay
+	step   int
+	run    int
+	inRun  int
+	force  int
+}
+
+func NewReplayPlayer(replay *Replay) *ReplayPlayer {
+	return &ReplayPlayer{replay: replay}
+}
+
+func (p *ReplayPlayer) Done() bool {
+	return p.run >= len(p.replay.Controls)
+}
+
+// Step advances the world one time step and applies that step's inputs,
+// the same way the play states do
+func (p *ReplayPlayer) Step(lw *LevelWorld) {
+	if p.Done() {
+		return
+	}
+	lw.World.Step(TimeStep, VelocityIterations, PositionIterations)
+	breakJoints(lw.World, lw.Joints)
+
+	run := p.replay.Controls[p.run]
+	lw.Car.Apply(run.Controls)
+
+	targets := forceTargets(lw.Car, lw.Bodies)
+	forces := p.replay.Forces
+	for ; p.force < len(forces) && forces[p.force].Step <= p.step; p.force++ {
+		f := forces[p.force]
+		if f.Step < p.step || f.Body < 0 || f.Body >= len(targets) {
+			continue
+		}
+		body := targets[f.Body].Body
+		body.ApplyForce(f.Force, body.GetWorldPoint(f.Local), true)
+	}
+
+	p.step++
+	p.inRun++
+	if p.inRun >= run.Steps {
+		p.run++
+		p.inRun = 0
+	}
+}
+
+// Simulate runs the replay headless on level and reports how it ended
+func (r *Replay) Simulate(level *LevelData) ReplayResult {
+	lw := NewLevelWorld(level, r.Vehicle)
+	player := NewReplayPlayer(r)
+	for !player.Done() {
+		player.Step(lw)
+	}
+	goalX := lw.Goal.Body.GetPosition().X
+	return ReplayResult{
+		ReachedGoal: carPastGoal(lw.Car, goalX),
+		Score:       calcScore(lw.Cargo, lw.Car, goalX),
+		Time:        r.Duration(),
+	}
+}
+
+// forceTargets are the bodies the mouse can push around, in replay order
+func forceTargets(car *Car, bodies []*GameBody) []*GameBody {
+	targets := car.Bodies()
+	return append(targets, bodies...)
+}
+
+// ReplayState plays back a leaderboard entry in the level it was driven on
+type ReplayState struct {
+	level    LevelInfo
+	entry    *LeaderboardEntry
+	player   *ReplayPlayer
+	world    *LevelWorld
+	previous LevelInfo
+	texts    [3]string
+}
+
+func (state *ReplayState) Init(g *Game) {
+	state.previous = *g.levelInfo
+	state.texts = [3]string{g.text.String(), g.sideText.String(), g.infoText.String()}
+
+	g.loadLevel(state.level)
+	state.world = g.buildWorld(state.entry.Replay.Vehicle)
+	state.player = NewReplayPlayer(state.entry.Replay)
+	g.showHud = true
+
+	g.text.Clear()
+	fmt.Fprintf(g.text, "Replay of %s", state.entry.Player)
+	g.sideText.Clear()
+	fmt.Fprintf(g.sideText, "%s to stop watching\n", g.key(ActionBack))
+}
+
+func (state *ReplayState) Update(g *Game) {
+	if !state.player.Done() {
+		start := time.Now()
+		state.player.Step(state.world)
+		g.Profiler.Add(SectionStep, time.Since(start))
+		g.followCar()
+	}
+
+	g.infoText.Clear()
+	fmt.Fprintf(g.infoText, "Time: %.1f s\n", float64(state.player.step)*TimeStep)
+	fmt.Fprintf(g.infoText, "Score: %d\n", g.CalcScore())
+	if state.player.Done() {
+		fmt.Fprintln(g.infoText, "Replay finished")
+	}
+
+	if g.justPressed(ActionBack) {
+		// Put back the level and texts of the screen below
+		g.loadLevel(state.previous)
+		g.showHud = false
+		restore := []*Label{g.text, g.sideText, g.infoText}
+		for i, label := range restore {
+			label.Clear()
+			fmt.Fprint(label, state.texts[i])
+		}
+		g.states.Pop()
+	}
+}
+
+func (state *ReplayState) Render(g *Game) {
+	g.text.Draw(g.Window)
+	g.sideText.Draw(g.Window)
+	g.infoText.Draw(g.Window)
+}
@@ -0,0 +1,176 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/faiface/pixel"
+)
+
+const (
+	edgeSnapDistance = 10 // Screen pixels within which edges line up
+	minGridSpacing   = 0.05
+	maxGridSpacing   = 10
+)
+
+// SnapSettings say how the editor lines bodies up
+type SnapSettings struct {
+	Grid        bool
+	GridSpacing float64 // Meters
+	Angle       bool
+	AngleStep   float64 // Degrees
+	Edges       bool
+}
+
+func DefaultSnapSettings() SnapSettings {
+	return SnapSettings{GridSpacing: 1, AngleStep: 15}
+}
+
+// String lists the snapping in use for the info panel
+func (s SnapSettings) String() string {
+	var on []string
+	if s.Grid {
+		on = append(on, fmt.Sprintf("grid %g m", s.GridSpacing))
+	}
+	if s.Angle {
+		on = append(on, fmt.Sprintf("angle %g°", s.AngleStep))
+	}
+	if s.Edges {
+		on = append(on, "edges")
+	}
+	if len(on) == 0 {
+		return "Snap: off"
+	}
+	return "Snap: " + strings.Join(on, ", ")
+}
+
+func (s SnapSettings) snapValue(v float64) float64 {
+	return math.Round(v/s.GridSpacing) * s.GridSpacing
+}
+
+// snapAngle rounds an angle in radians to the angle step
+func (s SnapSettings) snapAngle(angle float64) float64 {
+	step := s.AngleStep * math.Pi / 180
+	return math.Round(angle/step) * step
+}
+
+// rotateStep is how far one press of a rotate key turns the selection, and
+// whether rotating goes by presses rather than while the key is held
+func (s SnapSettings) rotateStep(angle float64, dir float64) (float64, bool) {
+	if !s.Angle || s.AngleStep <= 0 {
+		return dir * math.Pi / 160, false
+	}
+	step := s.AngleStep * math.Pi / 180
+	return s.snapAngle(angle+dir*step) - angle, true
+}
+
+// moveStep is how far one press of a move key moves the selection from pos,
+// and whether moving goes by presses rather than while the key is held
+func (s SnapSettings) moveStep(pos, dir pixel.Vec) (pixel.Vec, bool) {
+	if !s.Grid {
+		return dir.Scaled(0.01), false
+	}
+	target := pos.Add(dir.Scaled(s.GridSpacing))
+	if dir.X != 0 {
+		target.X = s.snapValue(target.X)
+	}
+	if dir.Y != 0 {
+		target.Y = s.snapValue(target.Y)
+	}
+	return target.Sub(pos), true
+}
+
+// snapMove adjusts delta, a proposed move of bodies, so the first body lands
+// on the grid and the edges of the group line up with those of nearby bodies
+func (g *Game) snapMove(bodies []*GameBody, delta pixel.Vec) pixel.Vec {
+	snap := g.profile.Settings.Snap
+	if len(bodies) == 0 {
+		return delta
+	}
+	if snap.Grid {
+		pos := bodies[0].Body.GetPosition()
+		target := pixel.V(pos.X, pos.Y).Add(delta)
+		delta = pixel.V(snap.snapValue(target.X)-pos.X, snap.snapValue(target.Y)-pos.Y)
+	}
+	if snap.Edges {
+		delta = delta.Add(g.edgeSnap(unionBounds(bodies).Moved(delta), bodies))
+	}
+	return delta
+}
+
+// edgeSnap is the smallest move that puts an edge of rect against or in line
+// with an edge of a body close to it, separately for each axis
+func (g *Game) edgeSnap(rect pixel.Rect, exclude []*GameBody) pixel.Vec {
+	limit := edgeSnapDistance / g.camera.PixelsPerMeter()
+	best := pixel.V(limit, limit)
+	found := [2]bool{}
+
+	others := append([]*GameBody{g.ground, g.goalBody}, g.Bodies...)
+	others = append(others, g.CargoBodies...)
+	for _, other := range others {
+		if containsBody(exclude, other) {
+			continue
+		}
+		b := bodyBounds(other)
+		near := pixel.R(b.Min.X-limit, b.Min.Y-limit, b.Max.X+limit, b.Max.Y+limit)
+		if !near.Intersects(rect) {
+			continue
+		}
+		for _, d := range edgeOffsets(rect.Min.X, rect.Max.X, b.Min.X, b.Max.X) {
+			if math.Abs(d) < math.Abs(best.X) {
+				best.X, found[0] = d, true
+			}
+		}
+		for _, d := range edgeOffsets(rect.Min.Y, rect.Max.Y, b.Min.Y, b.Max.Y) {
+			if math.Abs(d) < math.Abs(best.Y) {
+				best.Y, found[1] = d, true
+			}
+		}
+	}
+	if !found[0] {
+		best.X = 0
+	}
+	if !found[1] {
+		best.Y = 0
+	}
+	return best
+}
+
+// edgeOffsets are the moves that make an edge of min..max touch or line up
+// with an edge of otherMin..otherMax
+func edgeOffsets(min, max, otherMin, otherMax float64) []float64 {
+	return []float64{otherMax - min, otherMin - max, otherMin - min, otherMax - max}
+}
+
+func containsBody(bodies []*GameBody, body *GameBody) bool {
+	for _, b := range bodies {
+		if b == body {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEditSnap toggles the kinds of snapping and changes the grid spacing
+func handleEditSnap(g *Game) {
+	snap := &g.profile.Settings.Snap
+	changed := true
+	switch {
+	case g.justPressed(ActionEditSnapGrid):
+		snap.Grid = !snap.Grid
+	case g.justPressed(ActionEditSnapAngle):
+		snap.Angle = !snap.Angle
+	case g.justPressed(ActionEditSnapEdges):
+		snap.Edges = !snap.Edges
+	case g.justPressed(ActionEditGridFiner):
+		snap.GridSpacing = math.Max(minGridSpacing, snap.GridSpacing/2)
+	case g.justPressed(ActionEditGridCoarser):
+		snap.GridSpacing = math.Min(maxGridSpacing, snap.GridSpacing*2)
+	default:
+		changed = false
+	}
+	if changed {
+		g.saveProfile()
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
 	"golang.org/x/image/colornames"
 )
 
@@ -57,9 +56,15 @@ type EditState struct{}
 type RestartState struct{}
 type LoadingState struct {
 	levelInfo LevelInfo
+	edit      bool // Open the level in the editor instead of playing it
+}
+type EvolveState struct {
+	evolver *Evolver
+	message string // Outcome of the last export
 }
 
 func (state GameStartState) Init(g *Game) {
+	g.startText.SetColor(colornames.Black)
 	g.text.Clear()
 	fmt.Fprintln(g.text, "Normal mode")
 
@@ -68,9 +73,9 @@ func (state GameStartState) Init(g *Game) {
 	fmt.Fprintln(g.startText, "Carry the payload to the finish line")
 
 	g.sideText.Clear()
-	fmt.Fprintln(g.sideText, "Accelerate with <- and -> keys")
-	fmt.Fprintln(g.sideText, "Break with space")
-	fmt.Fprintln(g.sideText, "Restart with Enter")
+	fmt.Fprintf(g.sideText, "Accelerate with %s and %s\n", g.key(ActionReverse), g.key(ActionAccelerate))
+	fmt.Fprintf(g.sideText, "Break with %s\n", g.key(ActionBrake))
+	fmt.Fprintf(g.sideText, "Restart with %s\n", g.key(ActionRestart))
 	fmt.Println("GameStartState")
 }
 
@@ -86,111 +91,131 @@ func (state GameStartState) Update(g *Game) {
 	if elapsed.Seconds() > 2 {
 		g.startText.Clear()
 		ratio := float64((4 - elapsed.Seconds()) / 2.0)
-		g.startText.Color = color.RGBA{0, 0, 0, uint8(255 * ratio)}
+		g.startText.SetColor(color.RGBA{0, 0, 0, uint8(255 * ratio)})
 		fmt.Fprintln(g.startText, g.levelInfo.Name)
 		fmt.Fprintln(g.startText, "Carry the payload to the finish line")
 	}
 
-	g.World.Step(TimeStep, VelocityIterations, PositionIterations)
+	g.stepWorld()
 
-	pos := g.car.body.Body.GetPosition()
-	g.camera.X = pos.X - 5.0 // Follow car, 5.0 is half the screen
+	g.followCar()
 
 	handleCarControls(g)
 
-	if g.Window.JustPressed(pixelgl.KeyM) {
-		g.toggleGrid = !g.toggleGrid
-	}
-
-	if g.Window.JustPressed(pixelgl.KeyP) {
+	if g.justPressed(ActionPause) {
 		g.states.Pop()
 		g.states.Push(PauseState{})
 	}
 
-	if g.Window.JustPressed(pixelgl.KeyE) {
-		g.states.Pop()
-		g.states.Push(EditState{})
+	if g.justPressed(ActionToggleEdit) {
+		g.editPlayedLevel()
 	}
-	if g.Window.JustPressed(pixelgl.KeyEnter) {
+	if g.justPressed(ActionRestart) {
 		g.states.Pop()
 		g.states.Push(RestartState{})
 	}
-	if g.Window.JustPressed(pixelgl.KeyL) {
-		g.states.Pop()
-		info := LevelInfo{Name: "New level", Filename: "newLevel.json"}
-		g.states.Push(LoadingState{levelInfo: info})
+	if g.justPressed(ActionLoadEditLevel) {
+		g.confirmLeave(loadEditLevel)
 	}
 }
 
+// editPlayedLevel opens the level being played in the editor, which then
+// saves to its file. The world is built again so the editor starts from the
+// level as it was made rather than as it was played.
+func (g *Game) editPlayedLevel() {
+	g.editLevel = *g.levelInfo
+	g.buildWorld(g.car.def)
+	g.states.Pop()
+	g.states.Push(EditState{})
+}
+
+// loadEditLevel plays the level last opened in the editor
+func loadEditLevel(g *Game) {
+	g.states.Pop()
+	g.states.Push(LoadingState{levelInfo: g.editLevel})
+}
+
 func (state GameStartState) Render(g *Game) {
-	g.text.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 2))
-	g.sideText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 1))
-	g.startText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 3))
+	g.text.Draw(g.Window)
+	g.sideText.Draw(g.Window)
+	g.startText.Draw(g.Window)
 }
 
 func (state PlayState) Init(g *Game) {
 	g.text.Clear()
-	fmt.Fprintln(g.text, "Normal mode")
+	if g.playTest != nil {
+		fmt.Fprintln(g.text, g.playTestHelp())
+	} else {
+		fmt.Fprintln(g.text, "Normal mode")
+	}
 	fmt.Println("Playstate")
 
 	g.sideText.Clear()
-	fmt.Fprintln(g.sideText, "Accelerate with <- and -> keys")
-	fmt.Fprintln(g.sideText, "Break with space")
-	fmt.Fprintln(g.sideText, "Restart with Enter")
+	fmt.Fprintf(g.sideText, "Accelerate with %s and %s\n", g.key(ActionReverse), g.key(ActionAccelerate))
+	fmt.Fprintf(g.sideText, "Break with %s\n", g.key(ActionBrake))
+	fmt.Fprintf(g.sideText, "Restart with %s\n", g.key(ActionRestart))
+	fmt.Fprintf(g.sideText, "Evolve a vehicle with %s\n", g.key(ActionEvolve))
+	fmt.Fprintf(g.sideText, "Performance stats with %s\n", g.key(ActionToggleStats))
+	fmt.Fprintf(g.sideText, "Physics debug with %s\n", g.key(ActionToggleDebug))
 }
 
 func (state PlayState) Update(g *Game) {
+	if g.checkGoal() && g.playTest != nil {
+		g.finishPlayTest()
+		return
+	}
 	if g.checkGoal() {
 		g.states.Pop()
 		g.states.Push(FinishedState{})
 		return
 	}
-	g.World.Step(TimeStep, VelocityIterations, PositionIterations)
+	g.stepWorld()
 
-	pos := g.car.body.Body.GetPosition()
-	g.camera.X = pos.X - 5.0 // Follow car, 5.0 is half the screen
+	g.followCar()
 
 	handleCarControls(g)
 
-	if g.Window.JustPressed(pixelgl.KeyM) {
-		g.toggleGrid = !g.toggleGrid
-	}
-
-	if g.Window.JustPressed(pixelgl.KeyP) {
+	if g.justPressed(ActionPause) {
 		g.states.Pop()
 		g.states.Push(PauseState{})
 	}
 
-	if g.Window.JustPressed(pixelgl.KeyE) {
-		g.states.Pop()
-		g.states.Push(EditState{})
+	if g.justPressed(ActionToggleEdit) {
+		if g.playTest != nil {
+			g.endPlayTest()
+			return
+		}
+		g.editPlayedLevel()
 	}
 
-	if g.Window.JustPressed(pixelgl.KeyEnter) {
+	if g.justPressed(ActionRestart) {
 		g.states.Pop()
 		g.states.Push(RestartState{})
 	}
 
-	if g.Window.JustPressed(pixelgl.KeyL) {
+	if g.justPressed(ActionLoadEditLevel) {
+		g.confirmLeave(loadEditLevel)
+	}
+
+	if g.justPressed(ActionEvolve) && g.playTest == nil {
 		g.states.Pop()
-		info := LevelInfo{Name: "New level", Filename: "newLevel.json"}
-		g.states.Push(LoadingState{levelInfo: info})
+		g.states.Push(&EvolveState{evolver: NewEvolver(g.levelData, time.Now().UnixNano())})
+		return
 	}
 
 	handleForce(g)
 }
 
 func (state PlayState) Render(g *Game) {
-	g.text.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 2))
-	g.sideText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 1))
+	g.text.Draw(g.Window)
+	g.sideText.Draw(g.Window)
 
 	if g.isDragging {
 		g.imDraw.SetMatrix(pixel.IM)
 		g.imDraw.Color = colornames.Blueviolet
 		worldPos := g.forceDrag.body.GetWorldPoint(*g.forceDrag.localPos)
-		worldPos.X = worldPos.X - g.camera.X
 		screenPos := worldToScreen(&worldPos, g.camera)
-		g.imDraw.Push(*screenPos, g.Window.MousePosition())
+		g.imDraw.Push(*screenPos, g.input.MousePosition())
 		g.imDraw.Line(3)
 	}
 }
@@ -201,136 +226,175 @@ func (state PauseState) Init(g *Game) {
 	fmt.Fprintln(g.text, "Paused")
 
 	g.sideText.Clear()
-	fmt.Fprintln(g.sideText, "Accelerate with <- and -> keys")
-	fmt.Fprintln(g.sideText, "Break with space")
+	fmt.Fprintf(g.sideText, "Accelerate with %s and %s\n", g.key(ActionReverse), g.key(ActionAccelerate))
+	fmt.Fprintf(g.sideText, "Break with %s\n", g.key(ActionBrake))
+	if g.playTest != nil {
+		fmt.Fprintf(g.sideText, "%s to return to the editor\n", g.key(ActionBack))
+	} else {
+		fmt.Fprintf(g.sideText, "%s for the main menu\n", g.key(ActionBack))
+	}
 }
 
 func (state PauseState) Update(g *Game) {
-	if g.Window.JustPressed(pixelgl.KeyP) {
+	if g.justPressed(ActionPause) {
 		g.states.Pop()
 		g.states.Push(PlayState{})
 	}
+	if g.justPressed(ActionBack) && g.playTest != nil {
+		g.endPlayTest()
+		return
+	}
+	if g.justPressed(ActionBack) {
+		g.states.Pop()
+		g.states.Push(&MainMenuState{})
+	}
 }
 
 func (state PauseState) Render(g *Game) {
-	g.text.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 2))
-	g.sideText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 1))
+	g.text.Draw(g.Window)
+	g.sideText.Draw(g.Window)
 }
 
 func (state EditState) Init(g *Game) {
 	g.text.Clear()
-	fmt.Fprintln(g.text, "Edit mode")
+	fmt.Fprintln(g.text, g.editorStatus())
 	fmt.Println("EditState")
 
 	g.sideText.Clear()
-	fmt.Fprintln(g.sideText, "Press N for new body")
-	fmt.Fprintln(g.sideText, "Press Esc to cancel placement")
-	fmt.Fprintln(g.sideText, "Press S to save")
+	fmt.Fprintf(g.sideText, "Press %s for new body\n", g.key(ActionEditNewBody))
+	fmt.Fprintf(g.sideText, "Press %s to cancel placement\n", g.key(ActionBack))
+	fmt.Fprintf(g.sideText, "Press %s to save, %s to save as\n", g.key(ActionEditSave), g.key(ActionEditSaveAs))
+	fmt.Fprintf(g.sideText, "Press %s to open a level\n", g.key(ActionEditOpen))
+	fmt.Fprintf(g.sideText, "%s with nothing selected to leave\n", g.key(ActionBack))
 	fmt.Fprintln(g.sideText, "Arrows to change size")
-	fmt.Fprintln(g.sideText, "A and D to use camera")
+	fmt.Fprintf(g.sideText, "%s and %s or %s to pan\n", g.key(ActionEditPanLeft), g.key(ActionEditPanRight), g.key(ActionEditPanDrag))
+	fmt.Fprintln(g.sideText, "Mouse wheel to zoom")
+	fmt.Fprintf(g.sideText, "%s to frame all\n", g.key(ActionEditFrameAll))
+	fmt.Fprintf(g.sideText, "%s to frame selection\n", g.key(ActionEditFrameSelection))
+	fmt.Fprintf(g.sideText, "%s to undo, %s to redo\n", g.key(ActionEditUndo), g.key(ActionEditRedo))
+	fmt.Fprintln(g.sideText, "Shift click to add to the selection")
+	fmt.Fprintln(g.sideText, "Click the ground, goal or car to edit them")
+	fmt.Fprintf(g.sideText, "%s to join two bodies\n", g.key(ActionEditNewJoint))
+	fmt.Fprintln(g.sideText, "Drag on empty space to box select")
+	fmt.Fprintln(g.sideText, "Drag the selection or its handles")
+	fmt.Fprintf(g.sideText, "%s copy, %s paste, %s duplicate\n", g.key(ActionEditCopy), g.key(ActionEditPaste), g.key(ActionEditDuplicate))
+	fmt.Fprintf(g.sideText, "Snap: %s grid, %s angle, %s edges\n", g.key(ActionEditSnapGrid), g.key(ActionEditSnapAngle), g.key(ActionEditSnapEdges))
+	fmt.Fprintf(g.sideText, "%s and %s to change the grid\n", g.key(ActionEditGridFiner), g.key(ActionEditGridCoarser))
+	fmt.Fprintf(g.sideText, "%s to pick a property, %s and %s to change it\n", g.key(ActionEditNextField), g.key(ActionEditDecrease), g.key(ActionEditIncrease))
+	fmt.Fprintf(g.sideText, "Type a value and %s to set it\n", g.key(ActionConfirm))
 
 	g.editStates.Push(&MainEditState{})
 }
 
 func (state EditState) Update(g *Game) {
-	if g.Window.JustPressed(pixelgl.KeyE) {
-		for g.editStates.isEmpty() {
-			g.editStates.Pop()
-		}
-
-		g.states.Pop()
-		g.states.Push(PlayState{})
+	if g.justPressed(ActionToggleEdit) {
+		g.startPlayTest()
 		return
 	}
 
-	g.editStates.Top().Update(g)
+	// The inspector takes the input while a value is typed into it
+	if !g.inspector.Update(g, g.inspected(), g.inspectedJoint()) {
+		g.editStates.Top().Update(g)
+		handleEditSnap(g)
+	}
+	g.placeCar()
 
+	g.text.Clear()
+	fmt.Fprintln(g.text, g.editorStatus())
 	g.infoText.Clear()
-	if g.newBody != nil {
-		pos := g.newBody.Body.GetPosition()
-		angle := g.newBody.Body.GetAngle()
-		fmt.Fprintln(g.infoText, "Body info")
-		fmt.Fprintf(g.infoText, "X: %.2f\n", pos.X)
-		fmt.Fprintf(g.infoText, "Y: %.2f\n", pos.Y)
-		fmt.Fprintf(g.infoText, "Angle: %.2f\n", angle)
-		fmt.Fprintf(g.infoText, "Width: %.2f\n", g.newBody.HalfW*2)
-		fmt.Fprintf(g.infoText, "Height: %.2f\n", g.newBody.HalfH*2)
-		fmt.Fprintf(g.infoText, "Radius: %.2f\n", g.newBody.Radius)
-		fmt.Fprintf(g.infoText, "Density: %.1f\n", g.newBody.Density)
-		fmt.Fprintf(g.infoText, "Friction: %.1f\n", g.newBody.Friction)
-	} else {
-		for i := 0; i < len(g.Bodies); i++ {
-			if g.Bodies[i].IsSelected {
-				body := g.Bodies[i]
-				pos := body.Body.GetPosition()
-				angle := body.Body.GetAngle()
-				fmt.Fprintln(g.infoText, "Body info")
-				fmt.Fprintf(g.infoText, "X: %.2f\n", pos.X)
-				fmt.Fprintf(g.infoText, "Y: %.2f\n", pos.Y)
-				fmt.Fprintf(g.infoText, "Angle: %.2f\n", angle)
-				fmt.Fprintf(g.infoText, "Width: %.2f\n", body.HalfW*2)
-				fmt.Fprintf(g.infoText, "Height: %.2f\n", body.HalfH*2)
-				fmt.Fprintf(g.infoText, "Radius: %.2f\n", body.Radius)
-				fmt.Fprintf(g.infoText, "Density %.1f\n", body.Density)
-				fmt.Fprintf(g.infoText, "Friction %.1f\n", body.Friction)
-			}
+	fmt.Fprintln(g.infoText, g.profile.Settings.Snap)
+	g.inspector.show(g.inspected(), g.inspectedJoint())
+	g.inspector.Write(g, g.infoText)
+	if lines := g.editHistory.Lines(editHistoryShown); len(lines) > 0 {
+		fmt.Fprintln(g.infoText, "\nHistory")
+		for _, line := range lines {
+			fmt.Fprintln(g.infoText, line)
 		}
 	}
 
-	mousePos := g.Window.MousePosition()
-	pos := screenToWorld(mousePos, g.camera)
+	// The body being placed follows the mouse, snapped when snapping is on
 	if g.newBody != nil {
-		g.newBody.Body.SetTransform(pos, g.newBody.Body.GetAngle())
+		pos := g.newBody.Body.GetPosition()
+		mouse := g.camera.ScreenToWorld(g.input.MousePosition())
+		moveBodies([]*GameBody{g.newBody}, g.snapMove([]*GameBody{g.newBody}, mouse.Sub(pixel.V(pos.X, pos.Y))))
 	}
 
-	if g.Window.Pressed(pixelgl.KeyD) {
-		g.camera.X += 0.1
-	}
-	if g.Window.Pressed(pixelgl.KeyA) {
-		g.camera.X -= 0.1
-	}
+	handleEditCamera(g)
 
 	handleEditMode(g)
 }
 
 func (state EditState) Render(g *Game) {
-	g.text.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 2))
-	g.sideText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 1))
-	g.infoText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 1))
+	g.text.Draw(g.Window)
+	g.sideText.Draw(g.Window)
+	g.infoText.Draw(g.Window)
 
 	if g.newBody != nil {
 		g.newBody.Render(g, g.Window, g.imDraw)
 	}
-
+	g.renderJoints()
+	if r, ok := g.editStates.Top().(editRenderer); ok {
+		r.Render(g)
+	}
 }
 
 func (state FinishedState) Init(g *Game) {
 	g.levelIndex += 1
-	g.score += g.CalcScore()
+	levelScore := g.CalcScore()
+	g.score += levelScore
+	newBest := g.profile.Progress.Complete(g.levelInfo.Filename, levelScore, g.replay.Duration())
+	g.saveProfile()
+
 	g.finishedText.Clear()
 	fmt.Fprintln(g.finishedText, "Congrats you reached the goal")
 	fmt.Fprintf(g.finishedText, "Score: %d\n", g.score)
+	fmt.Fprintf(g.finishedText, "Time: %.1f s\n", g.replay.Duration())
+	if newBest {
+		fmt.Fprintln(g.finishedText, "New best for this level!")
+	}
+	if g.leaderboards != nil {
+		entry := LeaderboardEntry{Player: g.profile.Name, Date: time.Now(), Score: levelScore, Time: g.replay.Duration(), Replay: g.replay}
+		rank, err := g.leaderboards.Submit(entry)
+		if err != nil {
+			fmt.Println("Leaderboard entry rejected:", err)
+			fmt.Fprintln(g.finishedText, "Run could not be verified for the leaderboard")
+		} else if rank >= 0 {
+			fmt.Fprintf(g.finishedText, "Leaderboard rank #%d\n", rank+1)
+		}
+		fmt.Fprintf(g.finishedText, "Leaderboard with %s\n", g.key(ActionLeaderboard))
+	}
 
 	if g.levelIndex < len(g.config.Levels) {
-		fmt.Fprintln(g.finishedText, "Continue with Enter")
+		fmt.Fprintf(g.finishedText, "Continue with %s\n", g.key(ActionConfirm))
 	} else {
 		fmt.Fprintln(g.finishedText, "You have beaten the game")
+		fmt.Fprintf(g.finishedText, "%s for the main menu\n", g.key(ActionConfirm))
 	}
 
 }
 
 func (state FinishedState) Update(g *Game) {
-	if g.Window.JustPressed(pixelgl.KeyEnter) {
+	if g.justPressed(ActionConfirm) {
+		g.states.Pop()
 		if g.levelIndex < len(g.config.Levels) {
-			g.states.Pop()
-			g.states.Push(LoadingState{g.config.Levels[g.levelIndex]})
+			g.states.Push(LoadingState{levelInfo: g.config.Levels[g.levelIndex]})
+		} else {
+			g.states.Push(&MainMenuState{})
 		}
+		return
+	}
+	if g.justPressed(ActionBack) {
+		g.states.Pop()
+		g.states.Push(&MainMenuState{})
+	}
+	if g.justPressed(ActionLeaderboard) && g.leaderboards != nil {
+		g.states.Push(&LeaderboardState{level: *g.levelInfo})
 	}
 }
 
 func (state FinishedState) Render(g *Game) {
-	g.text.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 2))
-	g.finishedText.Draw(g.Window, pixel.IM.Scaled(g.text.Orig, 3))
+	g.text.Draw(g.Window)
+	g.finishedText.Draw(g.Window)
 }
 
 func (state RestartState) Init(g *Game) {
@@ -346,67 +410,40 @@ func (state RestartState) Render(g *Game) {
 }
 
 func handleRestart(g *Game) {
-	for i := 0; i < len(g.Bodies); i++ {
-		g.World.DestroyBody(g.Bodies[i].Body)
-	}
-	for i := 0; i < len(g.CargoBodies); i++ {
-		g.World.DestroyBody(g.CargoBodies[i].Body)
-	}
-	g.Bodies = CreateBodies(g, g.levelData.Bodies)
-	g.CargoBodies = CreateBodies(g, g.levelData.Cargo)
-	g.resetCar()
-}
-
-func DestroyWorld(g *Game) {
-	if g.ground != nil {
-		g.World.DestroyBody(g.ground.Body)
-	}
-	if g.goalBody != nil {
-		g.World.DestroyBody(g.goalBody.Body)
-	}
-	if g.car != nil {
-		if g.car.wheelJoint1 != nil {
-			g.World.DestroyJoint(g.car.wheelJoint1)
-		}
-		if g.car.wheelJoint2 != nil {
-			g.World.DestroyJoint(g.car.wheelJoint2)
-		}
-		if g.car.wheel1.Body != nil {
-			g.World.DestroyBody(g.car.wheel1.Body)
-		}
-		if g.car.wheel2.Body != nil {
-			g.World.DestroyBody(g.car.wheel2.Body)
-		}
-		if g.car.body.Body != nil {
-			g.World.DestroyBody(g.car.body.Body)
-		}
-	}
-
-	for i := 0; i < len(g.Bodies); i++ {
-		g.World.DestroyBody(g.Bodies[i].Body)
-	}
-	for i := 0; i < len(g.CargoBodies); i++ {
-		g.World.DestroyBody(g.CargoBodies[i].Body)
-	}
+	g.buildWorld(g.car.def)
 }
 
 func (state LoadingState) Init(g *Game) {
-	data := LoadFromFile(state.levelInfo.Filename)
-	g.levelData = data
-	g.levelInfo = &state.levelInfo
-
-	DestroyWorld(g)
-
-	g.ground, g.goalBody = CreateGroundAndGoal(g)
-	g.car = CreateCar(g)
-	g.Bodies = CreateBodies(g, data.Bodies)
-	g.CargoBodies = CreateBodies(g, data.Cargo)
+	g.loadLevel(state.levelInfo)
+	g.showHud = true
 }
 
 func (state LoadingState) Update(g *Game) {
 	g.states.Pop()
-	g.states.Push(GameStartState{startTime: time.Now()})
+	if state.edit {
+		g.editLevel = state.levelInfo
+		g.states.Push(EditState{})
+	} else {
+		g.states.Push(GameStartState{startTime: time.Now()})
+	}
 }
 
 func (state LoadingState) Render(g *Game) {
 }
+
+func (g *Game) loadLevel(info LevelInfo) {
+	data, err := ReadLevel(info.Filename)
+	if err != nil {
+		// A level that was never saved starts out empty
+		fmt.Println("Loading level failed:", err)
+		data = &LevelData{Name: info.Name}
+	}
+	g.playTest = nil
+	g.levelData = data
+	g.levelInfo = &info
+	g.backgrounds = loadBackgrounds(g.assets, data.Backgrounds)
+	g.buildWorld(g.vehicle)
+
+	carPos := g.car.body.Body.GetPosition()
+	g.camera.SnapTo(pixel.V(carPos.X, carPos.Y))
+}
@@ -0,0 +1,145 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/bytearena/box2d"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+func TestPlayTransitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(g *Game)
+		frames []InputFrame
+		want   string
+	}{
+		{
+			name:   "pause",
+			frames: tap(pixelgl.KeyP),
+			want:   "game.PauseState",
+		},
+		{
+			name:   "unpause",
+			frames: append(tap(pixelgl.KeyP), tap(pixelgl.KeyP)...),
+			want:   "game.PlayState",
+		},
+		{
+			name:   "restart",
+			frames: tap(pixelgl.KeyEnter),
+			want:   "game.PlayState",
+		},
+		{
+			name:   "menu from pause",
+			frames: append(tap(pixelgl.KeyP), tap(pixelgl.KeyEscape)...),
+			want:   "*game.MainMenuState",
+		},
+		{
+			name:   "edit",
+			frames: tap(pixelgl.KeyE),
+			want:   "game.EditState",
+		},
+		{
+			name: "goal",
+			setup: func(g *Game) {
+				goal := g.goalBody.Body.GetPosition()
+				g.car.spawn = box2d.B2Vec2{X: goal.X + 5, Y: g.car.spawn.Y}
+				g.car.Reset()
+			},
+			frames: []InputFrame{{}},
+			want:   "game.FinishedState",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			// The editor last had the other level open
+			g.editLevel = g.config.Levels[0]
+			playLevel(g, g.config.Levels[1])
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+			run(g, tt.frames...)
+			if got := stateName(g.states.Top()); got != tt.want {
+				t.Fatalf("state %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditorStates(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames func(g *Game) []InputFrame
+		want   string
+	}{
+		{
+			name:   "new body",
+			frames: func(g *Game) []InputFrame { return tap(pixelgl.KeyN) },
+			want:   "*game.PlacementState",
+		},
+		{
+			name:   "cancel new body",
+			frames: func(g *Game) []InputFrame { return append(tap(pixelgl.KeyN), tap(pixelgl.KeyEscape)...) },
+			want:   "*game.MainEditState",
+		},
+		{
+			name: "place new body",
+			frames: func(g *Game) []InputFrame {
+				return append(tap(pixelgl.KeyN), click(emptySpace(g))...)
+			},
+			want: "*game.MainEditState",
+		},
+		{
+			name:   "select body",
+			frames: func(g *Game) []InputFrame { return click(centerOnScreen(g, g.Bodies[0])) },
+			want:   "*game.SelectedState",
+		},
+		{
+			name: "deselect",
+			frames: func(g *Game) []InputFrame {
+				return append(click(centerOnScreen(g, g.Bodies[0])), click(emptySpace(g))...)
+			},
+			want: "*game.MainEditState",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			startLevel(g, g.config.Levels[0], true)
+			run(g, tt.frames(g)...)
+			if got := stateName(g.editStates.Top()); got != tt.want {
+				t.Fatalf("edit state %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// With grid snapping a held key moves the selection a step when pressed and
+// another whenever the key repeats
+func TestEditorMoveRepeats(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames []InputFrame
+		steps  float64
+	}{
+		{"tap", tap(pixelgl.KeyRight), 1},
+		{"hold", []InputFrame{Press(pixelgl.KeyRight), Press(pixelgl.KeyRight), Press(pixelgl.KeyRight), {}}, 1},
+		{"repeat", []InputFrame{Press(pixelgl.KeyRight), Repeat(pixelgl.KeyRight), Repeat(pixelgl.KeyRight), {}}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			startLevel(g, g.config.Levels[0], true)
+			g.profile.Settings.Snap = SnapSettings{Grid: true, GridSpacing: 1, AngleStep: 15}
+			body := g.Bodies[0]
+			g.selectBodies([]*GameBody{body})
+			start := g.profile.Settings.Snap.snapValue(body.Body.GetPosition().X)
+
+			run(g, tt.frames...)
+			if got, want := body.Body.GetPosition().X, start+tt.steps; got != want {
+				t.Errorf("x %v, want %v", got, want)
+			}
+		})
+	}
+}
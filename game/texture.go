@@ -0,0 +1,117 @@
+package game
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+type TextureMode string
+
+const (
+	TextureStretch TextureMode = "stretch"
+	TextureTile    TextureMode = "tile"
+)
+
+// BodyTexture is the sprite a body is drawn with. U and V offset the texture
+// as a fraction of its size.
+type BodyTexture struct {
+	Name   string
+	Mode   TextureMode
+	U      float64
+	V      float64
+	sprite *pixel.Sprite
+}
+
+func newBodyTexture(name string, mode TextureMode, u, v float64) *BodyTexture {
+	if name == "" {
+		return nil
+	}
+	if mode == "" {
+		mode = TextureStretch
+	}
+	return &BodyTexture{Name: name, Mode: mode, U: u, V: v}
+}
+
+// renderTexture draws the body's texture and returns false when it has none
+// or it failed to load, so the caller can fall back to outlines.
+func (body *GameBody) renderTexture(g *Game, win *pixelgl.Window) bool {
+	tex := body.Texture
+	if tex == nil {
+		return false
+	}
+	picture := g.assets.Picture(tex.Name)
+	if picture == nil {
+		return false
+	}
+	if tex.sprite == nil {
+		tex.sprite = pixel.NewSprite(nil, pixel.Rect{})
+	}
+
+	pos := body.Body.GetPosition()
+	m := g.camera.BodyMatrix(*toPixelVec(&pos), body.Body.GetAngle())
+
+	// Local extents of the body in unzoomed pixels
+	half := pixel.V(body.HalfW, body.HalfH).Scaled(Scale)
+	if body.Shape == Circle {
+		half = pixel.V(body.Radius, body.Radius).Scaled(Scale)
+	}
+	area := pixel.Rect{Min: half.Scaled(-1), Max: half}
+	bounds := picture.Bounds()
+
+	if tex.Mode == TextureTile && body.Shape == Rectangle {
+		drawTiled(win, tex, picture, area, m)
+		return true
+	}
+
+	// Stretch the texture, starting at the UV offset, over the whole body
+	frame := stretchFrame(bounds, tex.U, tex.V)
+	tex.sprite.Set(picture, frame)
+	stretch := pixel.IM.ScaledXY(pixel.ZV, pixel.V(area.W()/frame.W(), area.H()/frame.H()))
+	tex.sprite.Draw(win, stretch.Chained(m))
+	return true
+}
+
+// drawTiled repeats the texture at its native size over area, cropping the
+// tiles at the edges
+func drawTiled(win *pixelgl.Window, tex *BodyTexture, picture pixel.Picture, area pixel.Rect, m pixel.Matrix) {
+	bounds := picture.Bounds()
+	w, h := bounds.W(), bounds.H()
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	startX := area.Min.X - wrapOffset(tex.U)*w
+	startY := area.Min.Y - wrapOffset(tex.V)*h
+	for x := startX; x < area.Max.X; x += w {
+		for y := startY; y < area.Max.Y; y += h {
+			visible := pixel.R(math.Max(x, area.Min.X), math.Max(y, area.Min.Y), math.Min(x+w, area.Max.X), math.Min(y+h, area.Max.Y))
+			if visible.W() <= 0 || visible.H() <= 0 {
+				continue
+			}
+			frame := visible.Moved(pixel.V(bounds.Min.X-x, bounds.Min.Y-y))
+			tex.sprite.Set(picture, frame)
+			tex.sprite.Draw(win, pixel.IM.Moved(visible.Center()).Chained(m))
+		}
+	}
+}
+
+// stretchFrame is the part of a texture with bounds that is stretched over a
+// body, starting at the UV offset. Offsets are kept within the texture and
+// leave at least a pixel of it, as the frame would turn inside out otherwise.
+func stretchFrame(bounds pixel.Rect, u, v float64) pixel.Rect {
+	u = math.Max(0, math.Min(1-1/bounds.W(), u))
+	v = math.Max(0, math.Min(1-1/bounds.H(), v))
+	return pixel.R(bounds.Min.X+u*bounds.W(), bounds.Min.Y+v*bounds.H(), bounds.Max.X, bounds.Max.Y)
+}
+
+// wrapOffset is how far into a tile the tiling starts. Tiles repeat, so only
+// the fraction of the offset counts, and a negative one shifts the other way.
+func wrapOffset(offset float64) float64 {
+	m := math.Mod(offset, 1)
+	if m < 0 {
+		m += 1
+	}
+	return m
+}
@@ -0,0 +1,50 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+func TestWrapOffset(t *testing.T) {
+	tests := []struct {
+		offset, want float64
+	}{
+		{0, 0},
+		{0.25, 0.25},
+		{1.25, 0.25},
+		{-0.25, 0.75},
+		{-1.25, 0.75},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		if got := wrapOffset(tt.offset); got != tt.want {
+			t.Errorf("wrapOffset(%v) = %v, want %v", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestStretchFrame(t *testing.T) {
+	bounds := pixel.R(0, 0, 100, 50)
+	tests := []struct {
+		name string
+		u, v float64
+		want pixel.Rect
+	}{
+		{"none", 0, 0, bounds},
+		{"inside", 0.5, 0.2, pixel.R(50, 10, 100, 50)},
+		{"negative", -0.5, -1, bounds},
+		{"past the end", 1, 2.5, pixel.R(99, 49, 100, 50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stretchFrame(bounds, tt.u, tt.v)
+			if got != tt.want {
+				t.Errorf("frame %v, want %v", got, tt.want)
+			}
+			if got.W() <= 0 || got.H() <= 0 {
+				t.Errorf("frame %v is empty", got)
+			}
+		})
+	}
+}
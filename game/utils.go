@@ -2,6 +2,7 @@ package game
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/png"
@@ -20,13 +21,45 @@ func toBox2dVec(v *pixel.Vec) *box2d.B2Vec2 {
 }
 
 func worldToScreen(v *box2d.B2Vec2, cam *Camera) *pixel.Vec {
-	return &pixel.Vec{X: v.X * Scale, Y: v.Y * Scale}
+	screenPos := cam.WorldToScreen(*toPixelVec(v))
+	return &screenPos
 }
 
 func screenToWorld(v pixel.Vec, cam *Camera) box2d.B2Vec2 {
-	newVec := box2d.B2Vec2{X: v.X * InvScale, Y: v.Y * InvScale}
-	newVec.X += cam.X
-	return newVec
+	worldPos := cam.ScreenToWorld(v)
+	return *toBox2dVec(&worldPos)
+}
+
+// bodyBounds is the world space bounding box of a body's fixtures
+func bodyBounds(body *GameBody) pixel.Rect {
+	xf := body.Body.GetTransform()
+	bounds := pixel.Rect{}
+	first := true
+	for f := body.Body.GetFixtureList(); f != nil; f = f.GetNext() {
+		aabb := box2d.B2AABB{}
+		f.GetShape().ComputeAABB(&aabb, xf, 0)
+		r := pixel.R(aabb.LowerBound.X, aabb.LowerBound.Y, aabb.UpperBound.X, aabb.UpperBound.Y)
+		if first {
+			bounds = r
+			first = false
+		} else {
+			bounds = bounds.Union(r)
+		}
+	}
+	return bounds
+}
+
+// unionBounds is the bounding box of all bodies
+func unionBounds(bodies []*GameBody) pixel.Rect {
+	bounds := pixel.Rect{}
+	for i := 0; i < len(bodies); i++ {
+		if i == 0 {
+			bounds = bodyBounds(bodies[i])
+		} else {
+			bounds = bounds.Union(bodyBounds(bodies[i]))
+		}
+	}
+	return bounds
 }
 
 func loadPicture(path string) (pixel.Picture, error) {
@@ -43,20 +76,28 @@ func loadPicture(path string) (pixel.Picture, error) {
 }
 
 type BodyJson struct {
-	X         float64
-	Y         float64
-	Angle     float64
-	Hx        float64
-	Hy        float64
-	Radius    float64
-	Density   float64
-	Friction  float64
-	BodyType  uint8
-	BodyShape Shape
+	X           float64
+	Y           float64
+	Angle       float64
+	Hx          float64
+	Hy          float64
+	Radius      float64
+	Density     float64
+	Friction    float64
+	BodyType    uint8
+	BodyShape   Shape
+	Restitution float64     `json:",omitempty"`
+	Sensor      bool        `json:",omitempty"`
+	Texture     string      `json:",omitempty"`
+	TextureMode TextureMode `json:",omitempty"`
+	TextureU    float64     `json:",omitempty"`
+	TextureV    float64     `json:",omitempty"`
 }
 
 type ConfigData struct {
-	Levels []LevelInfo
+	Levels   []LevelInfo
+	Vehicle  string              `json:",omitempty"`
+	Bindings map[Action][]string `json:",omitempty"`
 }
 
 type LevelInfo struct {
@@ -64,85 +105,238 @@ type LevelInfo struct {
 	Filename string
 }
 
+type BoundsJson struct {
+	MinX float64
+	MinY float64
+	MaxX float64
+	MaxY float64
+}
+
+type ColorJson struct {
+	R uint8
+	G uint8
+	B uint8
+	A uint8
+}
+
+type BackgroundJson struct {
+	Image    string
+	Parallax float64
+	TileX    bool
+	TileY    bool
+	Tint     *ColorJson `json:",omitempty"`
+	OffsetX  float64
+	OffsetY  float64
+	Scale    float64
+}
+
+// GroundJson is the floor of a level, centered on X and Y
+type GroundJson struct {
+	X        float64
+	Y        float64
+	Length   float64
+	Height   float64
+	Friction *float64 `json:",omitempty"` // defaultGroundFriction when missing
+}
+
+const defaultGroundFriction = 0.8
+
+func (ground GroundJson) friction() float64 {
+	if ground.Friction != nil {
+		return *ground.Friction
+	}
+	return defaultGroundFriction
+}
+
+// GoalJson is the finish line, reached once the whole car is past it
+type GoalJson struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// SpawnJson is where the chassis of the car starts and the way it drives
+type SpawnJson struct {
+	X          float64
+	Y          float64
+	FacingLeft bool `json:",omitempty"`
+}
+
+// facing is 1 for a car driving to the right and -1 for one driving left
+func (s SpawnJson) facing() float64 {
+	if s.FacingLeft {
+		return -1
+	}
+	return 1
+}
+
+// JointEndJson is a body a joint holds on to, by its list and index in the
+// level, or the world. X and Y are the anchor, local to the body.
+type JointEndJson struct {
+	Index int
+	Cargo bool `json:",omitempty"`
+	World bool `json:",omitempty"`
+	X     float64
+	Y     float64
+}
+
+// JointJson is a joint between two bodies, or a body and the world. Which of
+// the other fields are used depends on the type. Angles are in radians.
+type JointJson struct {
+	Type           JointType
+	BodyA          JointEndJson
+	BodyB          JointEndJson
+	ReferenceAngle float64 `json:",omitempty"` // Angle of B minus angle of A when created
+	AxisX          float64 `json:",omitempty"` // Prismatic axis, local to A
+	AxisY          float64 `json:",omitempty"`
+	Length         float64 `json:",omitempty"` // Distance, or the longest a rope gets
+	Limit          bool    `json:",omitempty"`
+	Lower          float64 `json:",omitempty"`
+	Upper          float64 `json:",omitempty"`
+	Motor          bool    `json:",omitempty"`
+	MotorSpeed     float64 `json:",omitempty"`
+	MaxMotor       float64 `json:",omitempty"` // Torque of a revolute joint, force of a prismatic one
+	BreakForce     float64 `json:",omitempty"` // Pulled harder than this the joint breaks, 0 never breaks
+}
+
 type LevelData struct {
-	Name   string
-	Bodies []BodyJson
-	Cargo  []BodyJson
+	Name            string
+	Bodies          []BodyJson
+	Cargo           []BodyJson
+	Ground          *GroundJson      `json:",omitempty"`
+	Goal            *GoalJson        `json:",omitempty"`
+	Spawn           *SpawnJson       `json:",omitempty"`
+	Joints          []JointJson      `json:",omitempty"`
+	CameraBounds    *BoundsJson      `json:",omitempty"`
+	BackgroundColor *ColorJson       `json:",omitempty"`
+	Backgrounds     []BackgroundJson `json:",omitempty"`
 }
 
-func LoadConfig() *ConfigData {
+// LoadConfig reads config.json, which has to list at least one level
+func LoadConfig() (*ConfigData, error) {
 	bytes, err := os.ReadFile("config.json")
-
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	data := ConfigData{}
-	err = json.Unmarshal(bytes, &data)
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return nil, fmt.Errorf("config.json: %w", err)
+	}
+	if len(data.Levels) == 0 {
+		return nil, errors.New("config.json lists no levels")
+	}
+	return &data, nil
+}
 
+// SaveConfig writes the config back to config.json
+func SaveConfig(config *ConfigData) error {
+	file, err := json.MarshalIndent(config, "", "    ")
 	if err != nil {
-		panic(err)
+		return err
+	}
+	return os.WriteFile("config.json", file, 0666)
+}
+
+// LevelIndex is the position of a level file in the level list, or -1
+func (c *ConfigData) LevelIndex(filename string) int {
+	for i, level := range c.Levels {
+		if level.Filename == filename {
+			return i
+		}
 	}
+	return -1
+}
 
-	return &data
+// SaveToFile writes the level in the editor to the file of info
+func SaveToFile(g *Game, info LevelInfo) error {
+	file, err := json.MarshalIndent(g.editedLevel(info.Name), "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(info.Filename, file, 0666)
 }
 
-func SaveToFile(g *Game) {
-	data := LevelData{Name: "Dood"}
+// editedLevel is the level in the editor as level files store it. During a
+// play test that is the level as it was when the test started.
+func (g *Game) editedLevel(name string) LevelData {
+	if g.playTest != nil {
+		data := *g.playTest.level
+		data.Name = name
+		return data
+	}
+	data := LevelData{Name: name}
+	g.writeEntities(&data)
+	data.CameraBounds = g.levelData.CameraBounds
+	data.BackgroundColor = g.levelData.BackgroundColor
+	data.Backgrounds = g.levelData.Backgrounds
 
 	bodies := g.Bodies
 	for i := 0; i < len(bodies); i++ {
-		b := bodies[i]
-		pos := b.Body.GetPosition()
-		friction := b.Body.GetFixtureList().GetFriction()
-		density := b.Body.GetFixtureList().GetDensity()
-		angle := b.Body.GetAngle()
-		d := BodyJson{X: pos.X, Y: pos.Y, Angle: angle, Hx: b.HalfW, Hy: b.HalfH, Density: density, Friction: friction}
-		d.BodyType = b.Body.GetType()
-		d.BodyShape = b.Shape
-		d.Radius = b.Radius
-		d.BodyShape = b.Shape
-		data.Bodies = append(data.Bodies, d)
+		data.Bodies = append(data.Bodies, newBodyJson(bodies[i]))
 	}
 
 	cargo := g.CargoBodies
 	for i := 0; i < len(cargo); i++ {
-		b := cargo[i]
-		pos := b.Body.GetPosition()
-		friction := b.Body.GetFixtureList().GetFriction()
-		density := b.Body.GetFixtureList().GetDensity()
-		angle := b.Body.GetAngle()
-		d := BodyJson{X: pos.X, Y: pos.Y, Angle: angle, Hx: b.HalfW, Hy: b.HalfH, Density: density, Friction: friction}
-		d.BodyType = b.Body.GetType()
-		d.BodyShape = b.Shape
-		d.Radius = b.Radius
-		d.BodyShape = b.Shape
-		data.Cargo = append(data.Cargo, d)
-	}
-
-	fmt.Println(data.Cargo)
-
-	file, _ := json.MarshalIndent(data, "", " ")
-	err := os.WriteFile("newLevel.json", file, 0666)
+		data.Cargo = append(data.Cargo, newBodyJson(cargo[i]))
+	}
+	for _, j := range g.Joints {
+		data.Joints = append(data.Joints, g.jointJson(j))
+	}
+	return data
+}
+
+// newBodyJson describes a body the way level files store it
+func newBodyJson(b *GameBody) BodyJson {
+	pos := b.Body.GetPosition()
+	friction := b.Body.GetFixtureList().GetFriction()
+	density := b.Body.GetFixtureList().GetDensity()
+	angle := b.Body.GetAngle()
+	d := BodyJson{X: pos.X, Y: pos.Y, Angle: angle, Hx: b.HalfW, Hy: b.HalfH, Density: density, Friction: friction}
+	d.BodyType = b.Body.GetType()
+	d.BodyShape = b.Shape
+	d.Radius = b.Radius
+	d.Restitution = b.Restitution
+	d.Sensor = b.IsSensor
+	setBodyJsonTexture(&d, b.Texture)
+	return d
+}
+
+func setBodyJsonTexture(d *BodyJson, tex *BodyTexture) {
+	if tex == nil {
+		return
+	}
+	d.Texture = tex.Name
+	d.TextureMode = tex.Mode
+	d.TextureU = tex.U
+	d.TextureV = tex.V
+}
+
+func LoadFromFile(filepath string) *LevelData {
+	data, err := ReadLevel(filepath)
+
 	if err != nil {
 		panic(err)
 	}
-	println("File saved!")
+
+	return data
 }
 
-func LoadFromFile(filepath string) *LevelData {
+// ReadLevel is LoadFromFile for callers that can recover from a bad file
+func ReadLevel(filepath string) (*LevelData, error) {
 	bytes, err := os.ReadFile(filepath)
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	data := LevelData{}
 	err = json.Unmarshal(bytes, &data)
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return &data
+	return &data, nil
 }
@@ -0,0 +1,36 @@
+package game
+
+import (
+	"os"
+	"testing"
+)
+
+// A config that can't be used stops the game from starting instead of
+// crashing it
+func TestInitializeConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string // Contents of config.json, none when empty
+		ok     bool
+	}{
+		{name: "missing"},
+		{name: "malformed", config: `{"Levels": [`},
+		{name: "no levels", config: `{"Levels": []}`},
+		{name: "valid", config: `{"Levels": [{"Name": "Level 1", "Filename": "level1.json"}]}`, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir(t)
+			os.Remove("config.json")
+			if tt.config != "" {
+				if err := os.WriteFile("config.json", []byte(tt.config), 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			g := &Game{}
+			if err := g.Initialize(nil, nil); (err == nil) != tt.ok {
+				t.Fatalf("Initialize error %v, want error %v", err, !tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/bytearena/box2d"
+)
+
+type WheelDef struct {
+	X        float64
+	Y        float64
+	Radius   float64
+	Density  float64
+	Friction float64
+	Texture  string `json:",omitempty"`
+}
+
+// VehicleDef describes a car relative to its chassis origin. It is what the
+// evolver produces and what the config can point at to replace the stock car.
+type VehicleDef struct {
+	Name              string
+	Chassis           []box2d.B2Vec2
+	Density           float64
+	Friction          float64
+	Wheels            []WheelDef
+	MotorTorque       float64
+	MotorSpeed        float64
+	SuspensionHz      float64
+	SuspensionDamping float64
+}
+
+func DefaultVehicle() *VehicleDef {
+	return &VehicleDef{
+		Name: "Stock car",
+		Chassis: []box2d.B2Vec2{
+			{X: -1.3, Y: -0.2},
+			{X: 1.3, Y: -0.2},
+			{X: 1.3, Y: 0.2},
+			{X: -1.3, Y: 0.2},
+		},
+		Density:  0.5,
+		Friction: 0.8,
+		Wheels: []WheelDef{
+			{X: -0.9, Y: -0.2, Radius: 0.3, Density: 1.0, Friction: 1.0, Texture: "wheel.png"},
+			{X: 0.9, Y: -0.2, Radius: 0.3, Density: 1.0, Friction: 1.0, Texture: "wheel.png"},
+		},
+		MotorTorque:       2,
+		MotorSpeed:        20,
+		SuspensionHz:      4,
+		SuspensionDamping: 0.7,
+	}
+}
+
+func LoadVehicle(filepath string) (*VehicleDef, error) {
+	bytes, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	def := VehicleDef{}
+	if err := json.Unmarshal(bytes, &def); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath, err)
+	}
+	return &def, nil
+}
+
+func SaveVehicle(def *VehicleDef, filepath string) error {
+	file, err := json.MarshalIndent(def, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath, file, 0666)
+}
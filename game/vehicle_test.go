@@ -0,0 +1,97 @@
+package game
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/faiface/pixel/pixelgl"
+)
+
+func TestLoadVehicle(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string // Contents of the vehicle file, none when empty
+		ok       bool
+	}{
+		{name: "missing"},
+		{name: "malformed", contents: `{"Wheels": [`},
+		{name: "valid", contents: `{"Name": "Buggy"}`, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir(t)
+			if tt.contents != "" {
+				if err := os.WriteFile("vehicle.json", []byte(tt.contents), 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			def, err := LoadVehicle("vehicle.json")
+			if (err == nil) != tt.ok {
+				t.Fatalf("error %v, want error %v", err, !tt.ok)
+			}
+			if tt.ok && def.Name != "Buggy" {
+				t.Errorf("name %q, want Buggy", def.Name)
+			}
+		})
+	}
+}
+
+// A vehicle the config points at that can't be loaded is replaced by the stock
+// car
+func TestConfigVehicleFallback(t *testing.T) {
+	testDir(t)
+	config := `{"Levels": [{"Name": "Level 1", "Filename": "level1.json"}], "Vehicle": "missing.json"}`
+	if err := os.WriteFile("config.json", []byte(config), 0666); err != nil {
+		t.Fatal(err)
+	}
+	g := &Game{}
+	if err := g.Initialize(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.vehicle, DefaultVehicle()) {
+		t.Errorf("vehicle %q, want the stock car", g.vehicle.Name)
+	}
+}
+
+// Exporting from the evolver tells whether the design was written
+func TestEvolveExport(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"written", true},
+		{"fails", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			playLevel(g, g.config.Levels[0])
+			if !tt.ok {
+				// A directory in the way of the file
+				if err := os.Mkdir(evolveExportFile, 0777); err != nil {
+					t.Fatal(err)
+				}
+			}
+			run(g, tap(pixelgl.KeyV)...)
+			state, ok := g.states.Top().(*EvolveState)
+			if !ok {
+				t.Fatalf("state %s, want *game.EvolveState", stateName(g.states.Top()))
+			}
+			state.evolver.BestEver = &Candidate{Vehicle: DefaultVehicle()}
+
+			run(g, tap(pixelgl.KeyS)...)
+			want := "Exported to " + evolveExportFile
+			if !tt.ok {
+				want = "Export failed: "
+			}
+			if !strings.HasPrefix(state.message, want) {
+				t.Errorf("message %q, want %q", state.message, want)
+			}
+			if _, err := LoadVehicle(evolveExportFile); (err == nil) != tt.ok {
+				t.Errorf("loading the export: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// Resolution is a window size in pixels
+type Resolution struct {
+	Width, Height int
+}
+
+func (r Resolution) String() string {
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+// resolutions are the window sizes offered in the settings menu, smallest first
+var resolutions = []Resolution{
+	{960, 720},
+	{1280, 720},
+	{ScreenWidth, ScreenHeight},
+	{1600, 900},
+	{1920, 1080},
+	{2560, 1440},
+	{3840, 2160},
+}
+
+// resolution is the windowed size chosen in the settings, the default window
+// when none was chosen yet
+func (s *ProfileSettings) resolution() Resolution {
+	if s.Width <= 0 || s.Height <= 0 {
+		return Resolution{ScreenWidth, ScreenHeight}
+	}
+	return Resolution{s.Width, s.Height}
+}
+
+// nextResolution is the preset after current that fits on the monitor,
+// wrapping around to the smallest one
+func nextResolution(current Resolution, monitor *pixelgl.Monitor) Resolution {
+	maxW, maxH := 0.0, 0.0
+	if monitor != nil {
+		maxW, maxH = monitor.Size()
+	}
+	fits := func(r Resolution) bool {
+		return maxW == 0 || (float64(r.Width) <= maxW && float64(r.Height) <= maxH)
+	}
+
+	for _, r := range resolutions {
+		larger := r.Width*r.Height > current.Width*current.Height
+		if larger && fits(r) {
+			return r
+		}
+	}
+	return resolutions[0]
+}
+
+// applyWindowSettings resizes the window or makes it fullscreen on the primary
+// monitor, as the profile settings say
+func (g *Game) applyWindowSettings() {
+	if g.Window == nil {
+		return
+	}
+	settings := &g.profile.Settings
+	if settings.Fullscreen {
+		if g.Window.Monitor() == nil {
+			g.Window.SetMonitor(pixelgl.PrimaryMonitor())
+		}
+	} else {
+		if g.Window.Monitor() != nil {
+			g.Window.SetMonitor(nil)
+		}
+		r := settings.resolution()
+		g.Window.SetBounds(pixel.R(0, 0, float64(r.Width), float64(r.Height)))
+	}
+	g.camera.Screen = g.screenBounds()
+}
+
+// toggleFullscreen switches between fullscreen and the chosen window size
+func (g *Game) toggleFullscreen() {
+	g.profile.Settings.Fullscreen = !g.profile.Settings.Fullscreen
+	g.applyWindowSettings()
+	g.saveProfile()
+}
@@ -1,82 +1,154 @@
 package game
 
-import "github.com/bytearena/box2d"
-
-func CreateCar(g *Game) *Car {
-	carBodyDef := BoxDef{x: 3.5, y: 1.3, hx: 1.3, hy: 0.2, density: 0.5, friction: 0.8}
-	carBodyDef.bodyType = box2d.B2BodyType.B2_dynamicBody
-	carBody := createBox(carBodyDef, g.World)
-
-	wheelDef1 := BallDef{x: 2.6, y: 1.1, r: 0.3, density: 1.0, friction: 1.0}
-	wheelDef1.bodyType = box2d.B2BodyType.B2_dynamicBody
-	wheel1 := createBall(wheelDef1, g.World)
-
-	wheelDef2 := BallDef{x: 4.4, y: 1.1, r: 0.3, density: 1.0, friction: 1.0}
-	wheelDef2.bodyType = box2d.B2BodyType.B2_dynamicBody
-	wheel2 := createBall(wheelDef2, g.World)
-
-	motorDef := box2d.MakeB2WheelJointDef()
-	motorDef.Initialize(carBody.Body, wheel1.Body, wheel1.Body.GetWorldCenter(), box2d.B2Vec2{X: 0, Y: 1})
-	motorDef.MaxMotorTorque = 2
-	motorDef.DampingRatio = 0.7
-	motorDef.FrequencyHz = 4
-	joint1 := g.World.CreateJoint(&motorDef)
-	wheelJoint1, ok := joint1.(*box2d.B2WheelJoint)
-	if !ok {
-		panic("Could not convert joint")
+import (
+	"math"
+
+	"github.com/bytearena/box2d"
+)
+
+// Levels that don't place the ground, the goal or the car get these
+var (
+	DefaultGround = GroundJson{X: 35, Y: 0.3, Length: 100, Height: 1}
+	DefaultGoal   = GoalJson{X: 50, Y: 2.4, Width: 0.2, Height: 3.6}
+	DefaultSpawn  = SpawnJson{X: 3.5, Y: 1.3}
+)
+
+func (level *LevelData) ground() GroundJson {
+	if level.Ground != nil {
+		return *level.Ground
 	}
+	return DefaultGround
+}
 
-	motorDef2 := box2d.MakeB2WheelJointDef()
-	motorDef2.Initialize(carBody.Body, wheel2.Body, wheel2.Body.GetWorldCenter(), box2d.B2Vec2{X: 0, Y: 1})
-	motorDef2.MaxMotorTorque = 2
-	motorDef2.DampingRatio = 0.7
-	motorDef2.FrequencyHz = 4
-	joint2 := g.World.CreateJoint(&motorDef2)
-	wheelJoint2, ok := joint2.(*box2d.B2WheelJoint)
+func (level *LevelData) goal() GoalJson {
+	if level.Goal != nil {
+		return *level.Goal
+	}
+	return DefaultGoal
+}
 
-	if !ok {
-		panic("Could not convert joint")
+func (level *LevelData) spawn() SpawnJson {
+	if level.Spawn != nil {
+		return *level.Spawn
 	}
+	return DefaultSpawn
+}
 
-	car := &Car{}
-	car.body = carBody
-	car.wheel1 = wheel1
-	car.wheel2 = wheel2
-	car.wheelJoint1 = wheelJoint1
-	car.wheelJoint2 = wheelJoint2
+// CreateCar builds the car with its chassis at the spawn point. A car facing
+// left is the mirror image of the vehicle and drives to the left.
+func CreateCar(world *box2d.B2World, def *VehicleDef, spawn SpawnJson) *Car {
+	facing := spawn.facing()
+	chassis := make([]box2d.B2Vec2, len(def.Chassis))
+	for i, v := range def.Chassis {
+		chassis[i] = box2d.B2Vec2{X: v.X * facing, Y: v.Y}
+	}
+	chassisDef := PolygonDef{x: spawn.X, y: spawn.Y, vertices: chassis, density: def.Density, friction: def.Friction}
+	chassisDef.bodyType = box2d.B2BodyType.B2_dynamicBody
+	carBody := createPolygon(chassisDef, world)
+
+	car := &Car{def: def, spawn: box2d.B2Vec2{X: spawn.X, Y: spawn.Y}, facing: facing, body: carBody}
+
+	for i := 0; i < len(def.Wheels); i++ {
+		wheelDef := def.Wheels[i]
+		ballDef := BallDef{x: spawn.X + wheelDef.X*facing, y: spawn.Y + wheelDef.Y, r: wheelDef.Radius, density: wheelDef.Density, friction: wheelDef.Friction}
+		ballDef.bodyType = box2d.B2BodyType.B2_dynamicBody
+		wheel := createBall(ballDef, world)
+		wheel.Texture = newBodyTexture(wheelDef.Texture, TextureStretch, 0, 0)
+
+		motorDef := box2d.MakeB2WheelJointDef()
+		motorDef.Initialize(carBody.Body, wheel.Body, wheel.Body.GetWorldCenter(), box2d.B2Vec2{X: 0, Y: 1})
+		motorDef.MaxMotorTorque = def.MotorTorque
+		motorDef.DampingRatio = def.SuspensionDamping
+		motorDef.FrequencyHz = def.SuspensionHz
+		joint := world.CreateJoint(&motorDef)
+		wheelJoint, ok := joint.(*box2d.B2WheelJoint)
+		if !ok {
+			panic("Could not convert joint")
+		}
+
+		car.wheels = append(car.wheels, wheel)
+		car.wheelJoints = append(car.wheelJoints, wheelJoint)
+	}
 
 	return car
 }
 
-func CreateGroundAndGoal(g *Game) (*GameBody, *GameBody) {
-	groundDef := BoxDef{x: 35, y: 0.3, hx: 50, hy: 0.5, density: 1.0, friction: 0.8}
-	ground := createBox(groundDef, g.World)
+// LevelWorld is a world with everything a level needs to be played
+type LevelWorld struct {
+	World  *box2d.B2World
+	Ground *GameBody
+	Goal   *GameBody
+	Car    *Car
+	Bodies []*GameBody
+	Cargo  []*GameBody
+	Anchor *box2d.B2Body // Holds joints to the world
+	Joints []*GameJoint
+}
 
-	goalDef := BoxDef{x: 50, y: 2.4, hx: 0.1, hy: 1.8, density: 1.0, friction: 0.8, isSensor: true}
-	goal := createBox(goalDef, g.World)
+// NewLevelWorld builds level in a new world. Bodies are always created in the
+// same order so equal inputs give equal simulations.
+func NewLevelWorld(level *LevelData, vehicle *VehicleDef) *LevelWorld {
+	world := box2d.MakeB2World(Gravity)
+	lw := &LevelWorld{World: &world}
+	lw.Ground, lw.Goal = CreateGroundAndGoal(lw.World, level.ground(), level.goal())
+	lw.Car = CreateCar(lw.World, vehicle, level.spawn())
+	lw.Bodies = CreateBodies(lw.World, level.Bodies)
+	lw.Cargo = CreateBodies(lw.World, level.Cargo)
+	for _, cargo := range lw.Cargo {
+		cargo.IsCargo = true
+	}
+	lw.Anchor = newWorldAnchor(lw.World)
+	lw.Joints = CreateJoints(lw.World, lw.Anchor, lw.Bodies, lw.Cargo, level.Joints)
+	return lw
+}
+
+func DestroyCar(car *Car, world *box2d.B2World) {
+	for i := 0; i < len(car.wheelJoints); i++ {
+		world.DestroyJoint(car.wheelJoints[i])
+	}
+	for i := 0; i < len(car.wheels); i++ {
+		world.DestroyBody(car.wheels[i].Body)
+	}
+	if car.body != nil {
+		world.DestroyBody(car.body.Body)
+	}
+}
+
+func CreateGroundAndGoal(world *box2d.B2World, groundData GroundJson, goalData GoalJson) (*GameBody, *GameBody) {
+	groundDef := BoxDef{x: groundData.X, y: groundData.Y, hx: groundData.Length / 2, hy: groundData.Height / 2, density: 1.0, friction: groundData.friction()}
+	ground := createBox(groundDef, world)
+
+	goalDef := BoxDef{x: goalData.X, y: goalData.Y, hx: goalData.Width / 2, hy: goalData.Height / 2, density: 1.0, friction: 0.8, isSensor: true}
+	goal := createBox(goalDef, world)
+	goal.IsSensor = true
 
 	return ground, goal
 }
 
-func CreateBodies(g *Game, bodies []BodyJson) []*GameBody {
+func CreateBodies(world *box2d.B2World, bodies []BodyJson) []*GameBody {
 	// Create bodies
 	var newBodies []*GameBody
 
 	for i := 0; i < len(bodies); i++ {
 		body := bodies[i]
+		var b *GameBody
 		if body.BodyShape == Rectangle {
-			boxDef := BoxDef{x: body.X, y: body.Y, hx: body.Hx, hy: body.Hy, density: body.Density, friction: body.Friction}
+			boxDef := BoxDef{x: body.X, y: body.Y, hx: body.Hx, hy: body.Hy, density: body.Density, friction: body.Friction, isSensor: body.Sensor}
 			boxDef.bodyType = body.BodyType
-			box := createBox(boxDef, g.World)
-			box.Body.SetTransform(box.Body.GetPosition(), body.Angle)
-			newBodies = append(newBodies, box)
+			b = createBox(boxDef, world)
 		} else if body.BodyShape == Circle {
-			ballDef := BallDef{x: body.X, y: body.Y, r: body.Radius, density: body.Density, friction: body.Friction}
+			ballDef := BallDef{x: body.X, y: body.Y, r: body.Radius, density: body.Density, friction: body.Friction, isSensor: body.Sensor}
 			ballDef.bodyType = body.BodyType
-			ball := createBall(ballDef, g.World)
-			ball.Body.SetTransform(ball.Body.GetPosition(), body.Angle)
-			newBodies = append(newBodies, ball)
+			b = createBall(ballDef, world)
+		} else {
+			continue
 		}
+		b.Body.SetTransform(b.Body.GetPosition(), body.Angle)
+		b.Body.GetFixtureList().SetRestitution(body.Restitution)
+		b.Restitution = body.Restitution
+		b.IsSensor = body.Sensor
+		b.Texture = newBodyTexture(body.Texture, body.TextureMode, body.TextureU, body.TextureV)
+		newBodies = append(newBodies, b)
 	}
 	return newBodies
 }
@@ -100,6 +172,34 @@ func createBox(def BoxDef, world *box2d.B2World) *GameBody {
 	return &GameBody{Body: boxBody, HalfW: def.hx, HalfH: def.hy, Density: def.density, Friction: def.friction, Shape: Rectangle}
 }
 
+func createPolygon(def PolygonDef, world *box2d.B2World) *GameBody {
+	polyDef := box2d.MakeB2BodyDef()
+	polyDef.Position.Set(def.x, def.y)
+	polyDef.Type = def.bodyType
+	polyBody := world.CreateBody(&polyDef)
+
+	polyShape := box2d.B2PolygonShape{}
+	polyShape.Set(def.vertices, len(def.vertices))
+
+	polyFixDef := box2d.MakeB2FixtureDef()
+	polyFixDef.Shape = &polyShape
+	polyFixDef.Density = def.density
+	polyFixDef.Friction = def.friction
+	polyFixDef.IsSensor = def.isSensor
+	polyBody.CreateFixtureFromDef(&polyFixDef)
+
+	// Keep the hull box2d computed so rendering matches the fixture
+	vertices := make([]box2d.B2Vec2, polyShape.M_count)
+	copy(vertices, polyShape.M_vertices[:polyShape.M_count])
+
+	body := &GameBody{Body: polyBody, Vertices: vertices, Shape: Polygon, Density: def.density, Friction: def.friction}
+	for i := 0; i < len(vertices); i++ {
+		body.HalfW = math.Max(body.HalfW, math.Abs(vertices[i].X))
+		body.HalfH = math.Max(body.HalfH, math.Abs(vertices[i].Y))
+	}
+	return body
+}
+
 func createBall(def BallDef, world *box2d.B2World) *GameBody {
 	ballDef := box2d.MakeB2BodyDef()
 	ballDef.Position.Set(def.x, def.y)
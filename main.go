@@ -1,18 +1,20 @@
 package main
 
 import (
+	"time"
+
 	"github.com/VashieO/physics/game"
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
-	"golang.org/x/image/colornames"
 )
 
 func run() {
 	cfg := pixelgl.WindowConfig{
-		Title:  "Pixel Rocks!",
-		Bounds: pixel.R(0, 0, game.ScreenWidth, game.ScreenHeight),
-		VSync:  true,
+		Title:     "Pixel Rocks!",
+		Bounds:    pixel.R(0, 0, game.ScreenWidth, game.ScreenHeight),
+		VSync:     true,
+		Resizable: true,
 	}
 	win, err := pixelgl.NewWindow(cfg)
 	if err != nil {
@@ -22,16 +24,26 @@ func run() {
 	imd := imdraw.New(nil)
 
 	gameObj := &game.Game{}
-	gameObj.Initialize(win, imd)
+	if err := gameObj.Initialize(win, imd); err != nil {
+		panic(err)
+	}
 
-	for !win.Closed() {
+	for !win.Closed() || !gameObj.CanClose() {
+		gameObj.Profiler.BeginFrame()
 		imd.Clear()
-		win.Clear(colornames.Aliceblue)
+		win.Clear(gameObj.ClearColor())
 		gameObj.Update(win)
+
+		renderStart := time.Now()
 		gameObj.Draw(win, imd)
 		imd.Draw(win)
+		gameObj.Profiler.Add(game.SectionRender, time.Since(renderStart))
+
 		win.Update()
+		gameObj.Profiler.EndFrame(gameObj.World)
 	}
+	gameObj.Profiler.StopRecording()
+	gameObj.Close()
 }
 
 func main() {